@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	stdlog "log"
+	"net"
 	"net/http"
 	"os/signal"
 	"syscall"
@@ -14,11 +17,19 @@ import (
 
 	"link-service/internal/config"
 	"link-service/internal/logger"
+	"link-service/internal/repository"
 	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/memory"
+	"link-service/internal/repository/sqlite"
 	"link-service/internal/server"
 	"link-service/internal/service"
+	"link-service/internal/webhook"
 )
 
+// flushTimeout bounds the time given to handlers to write their responses
+// once all checks are drained.
+const flushTimeout = 2 * time.Second
+
 func main() {
 	ctx, cancel := signal.NotifyContext(
 		context.Background(),
@@ -43,18 +54,40 @@ func main() {
 	}
 	defer log.Sync()
 
-	storage, err := filesystem.New(&cfg.Storage, log)
+	storage, err := newRepository(cfg, log)
 	if err != nil {
 		log.Fatal("cannot initialize storage: %v", zap.Error(err))
 		return
 	}
 
-	srv := service.New(storage, &cfg.Service, log)
+	if closer, ok := storage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// Callback URLs are supplied by clients, so callbacks are dialed through
+	// the same address guard as checked links. The sender only dials once
+	// it runs, after srv is set.
+	var srv *service.Service
+	sender := webhook.New(storage, &cfg.Webhook, log, webhook.WithDialContext(func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return srv.DialContext(ctx, network, address)
+	}))
+
+	srv = service.New(storage, sender, &cfg.Service, log)
+	go sender.Run(ctx)
+
 	err = srv.ProcessTempRecords()
 	if err != nil {
 		log.Fatal("failed to process temp records: %v", zap.Error(err))
 	}
 
+	err = srv.LoadMonitors()
+	if err != nil {
+		log.Fatal("failed to load monitors: %v", zap.Error(err))
+	}
+
+	go srv.RunJobs(ctx)
+	go srv.RunMonitors(ctx)
+
 	serv := server.New(ctx, srv, &cfg.Logger, &cfg.HTTPServer, log, storage)
 
 	go func() {
@@ -67,11 +100,43 @@ func main() {
 	<-ctx.Done()
 	log.Info("received shutdown signal")
 
-	time.Sleep(cfg.HTTPServer.ShutdownTimeout)
+	// The http server keeps accepting requests while in-flight checks drain,
+	// new ones are answered in stopping mode and saved as temp records.
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer drainCancel()
+
+	err = srv.Shutdown(drainCtx)
+	if err != nil {
+		log.Warn("in-flight checks were interrupted", zap.Error(err))
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer flushCancel()
+
+	err = serv.Shutdown(flushCtx)
+	if err != nil {
+		log.Warn("failed to shutdown http server", zap.Error(err))
+	}
 
 	log.Info("application shutdown completed successfully")
 }
 
+func newRepository(cfg *config.Config, log *zap.Logger) (repository.Repository, error) {
+	switch cfg.StorageType {
+	case config.StorageFileSystem:
+		return filesystem.New(&cfg.Storage, log)
+
+	case config.StorageSQLite:
+		return sqlite.New(&cfg.SQLite, log)
+
+	case config.StorageMemory:
+		return memory.New(log), nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.StorageType)
+	}
+}
+
 func fetchConfigPath() string {
 	var cfgPath string
 
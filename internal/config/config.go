@@ -7,15 +7,26 @@ import (
 
 	"link-service/internal/logger"
 	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/sqlite"
 	"link-service/internal/server"
 	"link-service/internal/service"
+	"link-service/internal/webhook"
+)
+
+const (
+	StorageFileSystem = "file_system"
+	StorageSQLite     = "sqlite"
+	StorageMemory     = "memory"
 )
 
 type Config struct {
-	HTTPServer server.Config
-	Storage    filesystem.Config
-	Service    service.Config
-	Logger     logger.Config
+	HTTPServer  server.Config
+	StorageType string `env:"STORAGE_TYPE" env-default:"file_system"`
+	Storage     filesystem.Config
+	SQLite      sqlite.Config
+	Service     service.Config
+	Logger      logger.Config
+	Webhook     webhook.Config
 }
 
 func New(path string) (*Config, error) {
@@ -1,11 +1,398 @@
 package domain
 
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	StatusAvailable    = "available"
+	StatusNotAvailable = "not available"
+	StatusUnknown      = "unknown"
+	StatusPending      = "pending"
+	StatusTimeout      = "timeout"
+	// StatusRedirected is an available link reached through redirects, which
+	// should be updated to its final URL.
+	StatusRedirected = "redirected"
+	// StatusBlocked is a link that resolves to an address checks may not
+	// connect to.
+	StatusBlocked = "blocked"
+	// StatusRateLimited is a link whose host answered 429 Too Many Requests
+	// on the last attempt.
+	StatusRateLimited = "rate_limited"
+	// StatusDisallowed is a link that was not checked because robots.txt of
+	// its host disallows it.
+	StatusDisallowed = "disallowed"
+	// StatusCircuitOpen is a link that was not checked because its host
+	// failed repeatedly and its circuit breaker is open.
+	StatusCircuitOpen = "circuit_open"
+
+	ErrorClassDNS     = "dns"
+	ErrorClassConnect = "connect"
+	// ErrorClassReset is a connection dropped by the server or a middlebox
+	// after it was established, usually a transient failure.
+	ErrorClassReset    = "reset"
+	ErrorClassTLS      = "tls"
+	ErrorClassTimeout  = "timeout"
+	ErrorClassHTTP     = "http"
+	ErrorClassRedirect = "redirect"
+	// ErrorClassInvalid is a link that cannot be checked, such as one with
+	// an unsupported scheme.
+	ErrorClassInvalid = "invalid"
+)
+
 type Record struct {
-	Links map[string]string `json:"links"`
-	ID    int64             `json:"links_num"`
+	// Links are the submitted links in submission order, duplicates included.
+	Links       []LinkEntry `json:"links"`
+	ID          int64       `json:"links_num"`
+	CallbackURL string      `json:"callback_url,omitempty"`
+	// Availability is set when the record was checked with a policy other
+	// than the service default.
+	Availability *Availability `json:"availability,omitempty"`
+	// Timing aggregates the timings of the checked links.
+	Timing *TimingStats `json:"timing,omitempty"`
+	// MonitorID is set on the records of monitor runs.
+	MonitorID int64 `json:"monitor_id,omitempty"`
+}
+
+// CheckedAt returns the time of the latest check of the links of r, or the
+// zero time if none of them was checked.
+func (r *Record) CheckedAt() time.Time {
+	var checkedAt time.Time
+	for _, entry := range r.Links {
+		if entry.Result.CheckedAt.After(checkedAt) {
+			checkedAt = entry.Result.CheckedAt
+		}
+	}
+
+	return checkedAt
+}
+
+// LinkEntry is a submitted link along with the result of its check.
+type LinkEntry struct {
+	// Index is the position of the link in the submitted list.
+	Index int `json:"index"`
+	// Link is the link as submitted.
+	Link string `json:"link"`
+	// Normalized is the link that was checked. Entries of the same link
+	// spelled differently share it, and the result of a single check.
+	Normalized string     `json:"normalized"`
+	Result     LinkResult `json:"result"`
+}
+
+// UnmarshalJSON also reads records saved before links were kept in order,
+// whose links were a map from link to result. Their links are sorted, as the
+// submission order is lost.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	type record Record
+	var rec struct {
+		record
+		Links json.RawMessage `json:"links"`
+	}
+
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+
+	*r = Record(rec.record)
+	r.Links = nil
+
+	links := bytes.TrimSpace(rec.Links)
+	if len(links) == 0 || bytes.Equal(links, []byte("null")) {
+		return nil
+	}
+
+	if links[0] != '{' {
+		return json.Unmarshal(links, &r.Links)
+	}
+
+	var legacy map[string]LinkResult
+	if err := json.Unmarshal(links, &legacy); err != nil {
+		return err
+	}
+
+	for i, link := range slices.Sorted(maps.Keys(legacy)) {
+		r.Links = append(r.Links, LinkEntry{
+			Index:      i,
+			Link:       link,
+			Normalized: link,
+			Result:     legacy[link],
+		})
+	}
+
+	return nil
+}
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Availability defines which responses count as available.
+type Availability struct {
+	Statuses        []StatusRange `json:"statuses"`
+	FollowRedirects bool          `json:"follow_redirects"`
+	// AuthAvailable makes 401 and 403 responses count as available, for links
+	// that are protected on purpose.
+	AuthAvailable bool `json:"auth_available"`
+}
+
+// Accepts reports whether a response with the given status code counts as
+// available.
+func (a Availability) Accepts(statusCode int) bool {
+	if a.AuthAvailable && (statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden) {
+		return true
+	}
+
+	for _, r := range a.Statuses {
+		if statusCode >= r.From && statusCode <= r.To {
+			return true
+		}
+	}
+
+	return false
 }
 
 type TempRecord struct {
 	Links []string `json:"links"`
 	ID    int64    `json:"links_num"`
 }
+
+// LinkResult is the outcome of a single link check.
+type LinkResult struct {
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"`
+	FinalURL   string    `json:"final_url,omitempty"`
+	LatencyMs  int64     `json:"latency_ms,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	CheckedAt  time.Time `json:"checked_at,omitzero"`
+	// Attempts is the number of attempts made, more than one for links that
+	// failed at first and were retried.
+	Attempts int `json:"attempts,omitempty"`
+	// Redirects are the hops that led to FinalURL, in order.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// TLS describes the certificate presented by https links.
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// Timing breaks down the duration of the last attempt.
+	Timing *Timing `json:"timing,omitempty"`
+	// Addresses are the addresses resolved by dns:// checks.
+	Addresses []string `json:"addresses,omitempty"`
+	// Cache tells whether the result was reused from a recent check, see the
+	// Cache constants. It is empty if the cache is disabled.
+	Cache string `json:"cache,omitempty"`
+}
+
+// Timing is the breakdown of a single check, in milliseconds. Phases of all
+// redirect hops add up, phases skipped thanks to a reused connection are 0.
+type Timing struct {
+	DNSMs     int64 `json:"dns_ms"`
+	ConnectMs int64 `json:"connect_ms"`
+	TLSMs     int64 `json:"tls_ms"`
+	// TTFBMs is the time from the start of the check to the first byte of the
+	// first response.
+	TTFBMs  int64 `json:"ttfb_ms"`
+	TotalMs int64 `json:"total_ms"`
+}
+
+// TimingStats aggregates the timings of the links of a record.
+type TimingStats struct {
+	Links   int    `json:"links"`
+	Mean    Timing `json:"mean"`
+	Max     Timing `json:"max"`
+	Slowest string `json:"slowest"`
+}
+
+// AggregateTimings returns the timing statistics of links, or nil if none of
+// them has a timing. Links checked together are counted once.
+func AggregateTimings(links []LinkEntry) *TimingStats {
+	var (
+		stats TimingStats
+		sum   Timing
+	)
+
+	seen := make(map[string]bool, len(links))
+	for _, entry := range links {
+		t := entry.Result.Timing
+		if t == nil || seen[entry.Normalized] {
+			continue
+		}
+		seen[entry.Normalized] = true
+
+		link := entry.Normalized
+
+		stats.Links++
+
+		sum.DNSMs += t.DNSMs
+		sum.ConnectMs += t.ConnectMs
+		sum.TLSMs += t.TLSMs
+		sum.TTFBMs += t.TTFBMs
+		sum.TotalMs += t.TotalMs
+
+		stats.Max.DNSMs = max(stats.Max.DNSMs, t.DNSMs)
+		stats.Max.ConnectMs = max(stats.Max.ConnectMs, t.ConnectMs)
+		stats.Max.TLSMs = max(stats.Max.TLSMs, t.TLSMs)
+		stats.Max.TTFBMs = max(stats.Max.TTFBMs, t.TTFBMs)
+
+		// Ties are broken by normalized link, so that the result does not
+		// depend on the order or the spelling of the links.
+		if stats.Slowest == "" || t.TotalMs > stats.Max.TotalMs || t.TotalMs == stats.Max.TotalMs && link < stats.Slowest {
+			stats.Max.TotalMs = t.TotalMs
+			stats.Slowest = link
+		}
+	}
+
+	if stats.Links == 0 {
+		return nil
+	}
+
+	n := int64(stats.Links)
+	stats.Mean = Timing{
+		DNSMs:     sum.DNSMs / n,
+		ConnectMs: sum.ConnectMs / n,
+		TLSMs:     sum.TLSMs / n,
+		TTFBMs:    sum.TTFBMs / n,
+		TotalMs:   sum.TotalMs / n,
+	}
+
+	return &stats
+}
+
+// TLSInfo describes the certificate chain presented by a server. It is filled
+// in even if verification failed.
+type TLSInfo struct {
+	Chain []Certificate `json:"chain"`
+	// DaysToExpiry is the number of days left until the leaf certificate
+	// expires, negative once it has expired.
+	DaysToExpiry     int    `json:"days_to_expiry"`
+	HostnameMismatch bool   `json:"hostname_mismatch,omitempty"`
+	VerifyError      string `json:"verify_error,omitempty"`
+	// Expiring is set when the leaf certificate expires within the configured
+	// window.
+	Expiring bool `json:"expiring,omitempty"`
+}
+
+// Certificate is a single certificate of a chain, leaf first.
+type Certificate struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	SANs     []string  `json:"sans,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// Redirect is a single hop of a redirect chain.
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// UnmarshalJSON also accepts the legacy form, where a link result was stored
+// as a bare status string.
+func (r *LinkResult) UnmarshalJSON(data []byte) error {
+	var status string
+	if err := json.Unmarshal(data, &status); err == nil {
+		*r = LinkResult{Status: status}
+		return nil
+	}
+
+	type linkResult LinkResult
+	var res linkResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+
+	*r = LinkResult(res)
+	return nil
+}
+
+const (
+	JobStateQueued  = "queued"
+	JobStateRunning = "running"
+	JobStateDone    = "done"
+	JobStateFailed  = "failed"
+)
+
+// Job describes the progress of an asynchronous link check. Its ID is the
+// links_num under which the resulting Record is saved.
+type Job struct {
+	ID      int64       `json:"job_id"`
+	State   string      `json:"state"`
+	Total   int         `json:"total"`
+	Checked int         `json:"checked"`
+	Links   []LinkEntry `json:"links"`
+	Error   string      `json:"error,omitempty"`
+}
+
+const (
+	// CacheHit is a result reused from a recent or concurrent check of the
+	// same link.
+	CacheHit = "hit"
+	// CacheMiss is a result of a check made because none was cached.
+	CacheMiss = "miss"
+	// CacheBypass is a result of a check made because the request asked not
+	// to use the cache.
+	CacheBypass = "bypass"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// Circuit is the state of the circuit breaker of a host. Hosts that are not
+// failing have no circuit.
+type Circuit struct {
+	Host     string `json:"host"`
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+	// RetryAt is the time an open circuit lets a probe check through.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// Monitor is a named set of links checked on a schedule. Every run is saved
+// as a record with the monitor ID.
+type Monitor struct {
+	ID    int64    `json:"monitor_id"`
+	Name  string   `json:"name"`
+	Links []string `json:"links"`
+	// Schedule is either an interval, such as "@every 5m", or a cron
+	// expression with five fields, evaluated in UTC.
+	Schedule     string        `json:"schedule"`
+	CallbackURL  string        `json:"callback_url,omitempty"`
+	Availability *Availability `json:"availability,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	// NextRunAt is zero if the schedule never fires again.
+	NextRunAt time.Time `json:"next_run_at,omitzero"`
+	// Uptime is the share of Runs in which every link was available.
+	Uptime float64 `json:"uptime"`
+	// Runs are the latest runs, oldest first.
+	Runs []MonitorRun `json:"runs"`
+}
+
+// MonitorRun summarizes a run of a monitor, saved as the record with the
+// given links_num.
+type MonitorRun struct {
+	ID        int64     `json:"links_num"`
+	StartedAt time.Time `json:"started_at"`
+	Total     int       `json:"total"`
+	Available int       `json:"available"`
+}
+
+// Up reports whether every link was available in the run.
+func (r MonitorRun) Up() bool {
+	return r.Available == r.Total
+}
+
+// Callback is a pending webhook delivery of the record with the given ID.
+type Callback struct {
+	ID  int64  `json:"links_num"`
+	URL string `json:"url"`
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantRec Record
+	}{
+		{
+			name: "legacy string statuses",
+			data: `{"links":{"google.com":"available","12dqf4wgf4.com":"not available"},"links_num":3}`,
+			wantRec: Record{
+				Links: []LinkEntry{
+					{Index: 0, Link: "12dqf4wgf4.com", Normalized: "12dqf4wgf4.com", Result: LinkResult{Status: StatusNotAvailable}},
+					{Index: 1, Link: "google.com", Normalized: "google.com", Result: LinkResult{Status: StatusAvailable}},
+				},
+				ID: 3,
+			},
+		},
+		{
+			name: "legacy structured results",
+			data: `{"links":{"google.com":{"status":"available","status_code":200,"final_url":"https://www.google.com/","latency_ms":42,"method":"HEAD","checked_at":"2025-01-02T03:04:05Z"}},"links_num":4,"callback_url":"https://example.com/hook"}`,
+			wantRec: Record{
+				Links: []LinkEntry{
+					{
+						Index:      0,
+						Link:       "google.com",
+						Normalized: "google.com",
+						Result: LinkResult{
+							Status:     StatusAvailable,
+							StatusCode: 200,
+							FinalURL:   "https://www.google.com/",
+							LatencyMs:  42,
+							Method:     "HEAD",
+							CheckedAt:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+						},
+					},
+				},
+				ID:          4,
+				CallbackURL: "https://example.com/hook",
+			},
+		},
+		{
+			name: "ordered entries",
+			data: `{"links":[{"index":0,"link":"Ya.ru","normalized":"https://ya.ru/","result":{"status":"available"}},{"index":1,"link":"ya.ru","normalized":"https://ya.ru/","result":{"status":"available"}}],"links_num":5}`,
+			wantRec: Record{
+				Links: []LinkEntry{
+					{Index: 0, Link: "Ya.ru", Normalized: "https://ya.ru/", Result: LinkResult{Status: StatusAvailable}},
+					{Index: 1, Link: "ya.ru", Normalized: "https://ya.ru/", Result: LinkResult{Status: StatusAvailable}},
+				},
+				ID: 5,
+			},
+		},
+		{
+			name:    "no links",
+			data:    `{"links":null,"links_num":6}`,
+			wantRec: Record{ID: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec Record
+			err := json.Unmarshal([]byte(tt.data), &rec)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRec, rec)
+		})
+	}
+}
+
+func TestAggregateTimings(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []LinkEntry
+		want  *TimingStats
+	}{
+		{
+			name:  "no timings",
+			links: []LinkEntry{{Link: "a.com", Normalized: "a.com", Result: LinkResult{Status: StatusUnknown}}},
+			want:  nil,
+		},
+		{
+			name: "mean and max",
+			links: []LinkEntry{
+				{Link: "a.com", Normalized: "a.com", Result: LinkResult{Timing: &Timing{DNSMs: 2, ConnectMs: 4, TLSMs: 10, TTFBMs: 20, TotalMs: 30}}},
+				{Link: "b.com", Normalized: "b.com", Result: LinkResult{Timing: &Timing{DNSMs: 4, ConnectMs: 2, TLSMs: 0, TTFBMs: 40, TotalMs: 50}}},
+				{Link: "c.com", Normalized: "c.com", Result: LinkResult{Status: StatusPending}},
+			},
+			want: &TimingStats{
+				Links:   2,
+				Mean:    Timing{DNSMs: 3, ConnectMs: 3, TLSMs: 5, TTFBMs: 30, TotalMs: 40},
+				Max:     Timing{DNSMs: 4, ConnectMs: 4, TLSMs: 10, TTFBMs: 40, TotalMs: 50},
+				Slowest: "b.com",
+			},
+		},
+		{
+			name: "ties",
+			links: []LinkEntry{
+				{Link: "b.com", Normalized: "b.com", Result: LinkResult{Timing: &Timing{TotalMs: 10}}},
+				{Link: "a.com", Normalized: "a.com", Result: LinkResult{Timing: &Timing{TotalMs: 10}}},
+			},
+			want: &TimingStats{
+				Links:   2,
+				Mean:    Timing{TotalMs: 10},
+				Max:     Timing{TotalMs: 10},
+				Slowest: "a.com",
+			},
+		},
+		{
+			name: "duplicates",
+			links: []LinkEntry{
+				{Link: "A.com", Normalized: "a.com", Result: LinkResult{Timing: &Timing{TotalMs: 30}}},
+				{Link: "a.com", Normalized: "a.com", Result: LinkResult{Timing: &Timing{TotalMs: 30}}},
+				{Link: "b.com", Normalized: "b.com", Result: LinkResult{Timing: &Timing{TotalMs: 10}}},
+			},
+			want: &TimingStats{
+				Links:   2,
+				Mean:    Timing{TotalMs: 20},
+				Max:     Timing{TotalMs: 30},
+				Slowest: "a.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AggregateTimings(tt.links))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+type createMonitorRequest struct {
+	Name         string               `json:"name"`
+	Links        []string             `json:"links"`
+	Schedule     string               `json:"schedule"`
+	CallbackURL  string               `json:"callback_url"`
+	Availability *availabilityRequest `json:"availability"`
+}
+
+func CreateMonitor(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createMonitorRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "cannot decode body", http.StatusBadRequest)
+			logger.Warn("cannot decode body", zap.Error(err))
+			return
+		}
+
+		if req.CallbackURL != "" {
+			err = srv.ValidateCallbackURL(r.Context(), req.CallbackURL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger.Warn("invalid callback url", zap.String("callback_url", req.CallbackURL), zap.Error(err))
+				return
+			}
+		}
+
+		linkErrs := validateLinks(srv, req.Links)
+		if len(linkErrs) > 0 {
+			_ = writeResponse(w, http.StatusBadRequest, validationResponse{Errors: linkErrs}, logger)
+			logger.Warn("invalid links", zap.Int("count", len(linkErrs)))
+			return
+		}
+
+		opts := service.Options{CallbackURL: req.CallbackURL}
+
+		if req.Availability != nil {
+			availability, err := availabilityPolicy(srv.Availability(), req.Availability)
+			if err != nil {
+				http.Error(w, "invalid availability: "+err.Error(), http.StatusBadRequest)
+				logger.Warn("invalid availability", zap.Error(err))
+				return
+			}
+
+			opts.Availability = &availability
+		}
+
+		monitor, err := srv.CreateMonitor(req.Name, req.Links, req.Schedule, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidMonitor):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger.Warn("invalid monitor", zap.Error(err))
+
+			case errors.Is(err, service.ErrMonitorExists):
+				http.Error(w, err.Error(), http.StatusConflict)
+				logger.Warn("monitor already exists", zap.String("name", req.Name))
+
+			default:
+				http.Error(w, "failed to create monitor", http.StatusInternalServerError)
+				logger.Error("failed to create monitor", zap.Error(err))
+			}
+
+			return
+		}
+
+		_ = writeResponse(w, http.StatusCreated, monitor, logger)
+	}
+}
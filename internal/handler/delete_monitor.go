@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+func DeleteMonitor(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid monitor id", http.StatusBadRequest)
+			logger.Warn("invalid monitor id", zap.Error(err))
+			return
+		}
+
+		err = srv.DeleteMonitor(id)
+		if err != nil {
+			if errors.Is(err, service.ErrMonitorNotFound) {
+				http.Error(w, "monitor not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "failed to delete monitor", http.StatusInternalServerError)
+			logger.Error("failed to delete monitor", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
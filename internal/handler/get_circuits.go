@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+func GetCircuits(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = writeResponse(w, http.StatusOK, srv.Circuits(), logger)
+	}
+}
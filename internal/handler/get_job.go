@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+func GetJob(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid job id", http.StatusBadRequest)
+			logger.Warn("invalid job id", zap.Error(err))
+			return
+		}
+
+		job, err := srv.Job(id)
+		if err != nil {
+			if errors.Is(err, service.ErrJobNotFound) {
+				http.Error(w, "job not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "failed to get job", http.StatusInternalServerError)
+			logger.Error("failed to get job", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		_ = writeResponse(w, http.StatusOK, job, logger)
+	}
+}
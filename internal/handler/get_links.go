@@ -2,13 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/jung-kurt/gofpdf"
 
+	"link-service/internal/domain"
 	"link-service/internal/repository"
 )
 
@@ -26,22 +29,40 @@ func GetLinks(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
 			return
 		}
 
+		records, err := repo.GetRecords(reqLinks.LinksList)
+		if err != nil {
+			http.Error(w, "failed to get records", http.StatusInternalServerError)
+			logger.Error("failed to get records", zap.Error(err))
+			return
+		}
+
 		pdf := gofpdf.New("P", "mm", "A4", "")
 		pdf.AddPage()
 		pdf.SetFont("Arial", "", 12)
 
 		for _, id := range reqLinks.LinksList {
-			rec, err := repo.GetRecord(id)
-			if err != nil {
-				logger.Warn("failed to get record", zap.Int64("id", id), zap.Error(err))
+			rec, ok := records[id]
+			if !ok {
+				logger.Warn("skipping missing or unreadable record", zap.Int64("id", id))
 				continue
 			}
 
 			pdf.CellFormat(0, 8, "Record: "+strconv.FormatInt(rec.ID, 10), "", 1, "", false, 0, "")
-			for link, status := range rec.Links {
-				pdf.CellFormat(0, 6, link+": "+status, "", 1, "", false, 0, "")
+			for _, entry := range rec.Links {
+				result := entry.Result
+				pdf.CellFormat(0, 6, formatLinkResult(entry.Link, result), "", 1, "", false, 0, "")
+
+				for _, hop := range result.Redirects {
+					pdf.CellFormat(0, 6, "    "+formatRedirect(hop), "", 1, "", false, 0, "")
+				}
+
+				if result.TLS != nil && len(result.TLS.Chain) > 0 {
+					pdf.CellFormat(0, 6, "    "+formatCertificate(result.TLS), "", 1, "", false, 0, "")
+				}
 			}
 
+			drawTimingChart(pdf, rec)
+
 			pdf.Ln(4)
 		}
 
@@ -54,3 +75,64 @@ func GetLinks(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
 		}
 	}
 }
+
+func formatLinkResult(link string, result domain.LinkResult) string {
+	var details []string
+
+	if result.StatusCode != 0 {
+		details = append(details, "code "+strconv.Itoa(result.StatusCode))
+	}
+	if result.Method != "" {
+		details = append(details, result.Method)
+	}
+	if result.LatencyMs != 0 {
+		details = append(details, fmt.Sprintf("%d ms", result.LatencyMs))
+	}
+	if result.ErrorClass != "" {
+		details = append(details, "error: "+result.ErrorClass)
+	}
+	if result.Attempts > 1 {
+		details = append(details, fmt.Sprintf("%d attempts", result.Attempts))
+	}
+	if len(result.Addresses) > 0 {
+		details = append(details, "addresses: "+strings.Join(result.Addresses, ", "))
+	}
+	if result.FinalURL != "" {
+		details = append(details, "final url: "+result.FinalURL)
+	}
+	if !result.CheckedAt.IsZero() {
+		details = append(details, "checked at "+result.CheckedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	if len(result.Redirects) > 0 {
+		details = append(details, fmt.Sprintf("%d redirects", len(result.Redirects)))
+	}
+	if result.TLS != nil && result.TLS.Expiring {
+		details = append(details, fmt.Sprintf("certificate expires in %d days", result.TLS.DaysToExpiry))
+	}
+	if result.TLS != nil && result.TLS.HostnameMismatch {
+		details = append(details, "certificate hostname mismatch")
+	}
+
+	line := link + ": " + result.Status
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return line
+}
+
+func formatRedirect(hop domain.Redirect) string {
+	return fmt.Sprintf("%d %s -> %s", hop.StatusCode, hop.URL, hop.Location)
+}
+
+func formatCertificate(info *domain.TLSInfo) string {
+	leaf := info.Chain[0]
+
+	line := fmt.Sprintf("certificate: %s, issuer %s, expires %s", leaf.Subject, leaf.Issuer, leaf.NotAfter.Format("2006-01-02"))
+	if info.VerifyError != "" {
+		line += ", " + info.VerifyError
+	}
+
+	return line
+}
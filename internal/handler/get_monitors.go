@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+func GetMonitors(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = writeResponse(w, http.StatusOK, srv.Monitors(), logger)
+	}
+}
+
+func GetMonitor(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid monitor id", http.StatusBadRequest)
+			logger.Warn("invalid monitor id", zap.Error(err))
+			return
+		}
+
+		monitor, err := srv.Monitor(id)
+		if err != nil {
+			if errors.Is(err, service.ErrMonitorNotFound) {
+				http.Error(w, "monitor not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "failed to get monitor", http.StatusInternalServerError)
+			logger.Error("failed to get monitor", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		_ = writeResponse(w, http.StatusOK, monitor, logger)
+	}
+}
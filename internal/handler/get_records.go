@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+const (
+	defaultRecordsLimit = 100
+	maxRecordsLimit     = 1000
+)
+
+func FindRecords(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := recordFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Warn("invalid records filter", zap.Error(err))
+			return
+		}
+
+		records, err := repo.FindRecords(filter)
+		if err != nil {
+			http.Error(w, "failed to find records", http.StatusInternalServerError)
+			logger.Error("failed to find records", zap.Error(err))
+			return
+		}
+
+		_ = writeResponse(w, http.StatusOK, records, logger)
+	}
+}
+
+// recordFilter parses the status, monitor_id, checked_from, checked_to and
+// limit query parameters. Times are RFC 3339.
+func recordFilter(query url.Values) (repository.RecordFilter, error) {
+	filter := repository.RecordFilter{
+		Status: query.Get("status"),
+		Limit:  defaultRecordsLimit,
+	}
+
+	if value := query.Get("monitor_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid monitor_id %q", value)
+		}
+
+		filter.MonitorID = id
+	}
+
+	for name, field := range map[string]*time.Time{
+		"checked_from": &filter.CheckedFrom,
+		"checked_to":   &filter.CheckedTo,
+	} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s %q, must be RFC 3339", name, value)
+		}
+
+		*field = t
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxRecordsLimit {
+			return filter, fmt.Errorf("invalid limit %q, must be 1-%d", value, maxRecordsLimit)
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
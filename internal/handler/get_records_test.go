@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository/memory"
+)
+
+func TestFindRecords(t *testing.T) {
+	checkedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	storage := memory.New(zap.NewNop())
+	for id, status := range map[int64]string{1: domain.StatusAvailable, 2: domain.StatusNotAvailable, 3: domain.StatusNotAvailable} {
+		rec := &domain.Record{
+			ID:    id,
+			Links: []domain.LinkEntry{{Link: "google.com", Normalized: "google.com", Result: domain.LinkResult{Status: status, CheckedAt: checkedAt.Add(time.Duration(id) * time.Hour)}}},
+		}
+		if id == 3 {
+			rec.MonitorID = 5
+		}
+		assert.NoError(t, storage.SaveRecord(rec))
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantIDs    []int64
+	}{
+		{query: "", wantStatus: http.StatusOK, wantIDs: []int64{1, 2, 3}},
+		{query: "?status=not+available", wantStatus: http.StatusOK, wantIDs: []int64{2, 3}},
+		{query: "?status=not+available&limit=1", wantStatus: http.StatusOK, wantIDs: []int64{2}},
+		{query: "?monitor_id=5", wantStatus: http.StatusOK, wantIDs: []int64{3}},
+		{query: "?checked_from=2026-01-01T14:00:00Z", wantStatus: http.StatusOK, wantIDs: []int64{2, 3}},
+		{query: "?checked_to=2026-01-01T14:00:00Z", wantStatus: http.StatusOK, wantIDs: []int64{1}},
+		{query: "?status=blocked", wantStatus: http.StatusOK, wantIDs: []int64{}},
+		{query: "?monitor_id=abc", wantStatus: http.StatusBadRequest},
+		{query: "?checked_from=yesterday", wantStatus: http.StatusBadRequest},
+		{query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{query: "?limit=1001", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			FindRecords(storage, zap.NewNop())(w, httptest.NewRequest(http.MethodGet, "/records"+tt.query, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var records []domain.Record
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+
+			ids := []int64{}
+			for _, rec := range records {
+				ids = append(ids, rec.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
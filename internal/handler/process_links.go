@@ -14,7 +14,19 @@ import (
 )
 
 type processLinksRequest struct {
-	Links []string `json:"links"`
+	Links        []string             `json:"links"`
+	Async        bool                 `json:"async"`
+	CallbackURL  string               `json:"callback_url"`
+	Availability *availabilityRequest `json:"availability"`
+	NoCache      bool                 `json:"no_cache"`
+}
+
+// availabilityRequest overrides parts of the default availability policy,
+// omitted fields keep their default.
+type availabilityRequest struct {
+	Statuses        *string `json:"statuses"`
+	FollowRedirects *bool   `json:"follow_redirects"`
+	AuthAvailable   *bool   `json:"auth_available"`
 }
 
 func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeout time.Duration, logger *zap.Logger) http.HandlerFunc {
@@ -30,10 +42,52 @@ func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeou
 			return
 		}
 
-		rec, err := srv.Process(serverCtx, requestCtx, reqLinks.Links)
+		if reqLinks.CallbackURL != "" {
+			err = srv.ValidateCallbackURL(requestCtx, reqLinks.CallbackURL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				logger.Warn("invalid callback url", zap.String("callback_url", reqLinks.CallbackURL), zap.Error(err))
+				return
+			}
+		}
+
+		linkErrs := validateLinks(srv, reqLinks.Links)
+		if len(linkErrs) > 0 {
+			_ = writeResponse(w, http.StatusBadRequest, validationResponse{Errors: linkErrs}, logger)
+			logger.Warn("invalid links", zap.Int("count", len(linkErrs)))
+			return
+		}
+
+		opts := service.Options{
+			CallbackURL: reqLinks.CallbackURL,
+			NoCache:     reqLinks.NoCache,
+		}
+
+		if reqLinks.Availability != nil {
+			availability, err := availabilityPolicy(srv.Availability(), reqLinks.Availability)
+			if err != nil {
+				http.Error(w, "invalid availability: "+err.Error(), http.StatusBadRequest)
+				logger.Warn("invalid availability", zap.Error(err))
+				return
+			}
+
+			opts.Availability = &availability
+		}
+
+		if reqLinks.Async {
+			submitLinks(w, serverCtx, srv, reqLinks.Links, opts, logger)
+			return
+		}
+
+		rec, err := srv.Process(serverCtx, requestCtx, reqLinks.Links, opts)
 		if err != nil {
 			if errors.Is(err, service.ErrAppStopped) {
-				err = writeResponse(w, rec, logger)
+				_ = writeResponse(w, http.StatusCreated, rec, logger)
+				return
+			}
+
+			if errors.Is(err, service.ErrRequestTimeout) {
+				_ = writeResponse(w, http.StatusAccepted, rec, logger)
 				return
 			}
 
@@ -42,15 +96,59 @@ func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeou
 			return
 		}
 
-		err = writeResponse(w, rec, logger)
+		_ = writeResponse(w, http.StatusCreated, rec, logger)
 	}
 }
 
-func writeResponse(w http.ResponseWriter, rec *domain.Record, logger *zap.Logger) error {
-	w.WriteHeader(http.StatusCreated)
+func submitLinks(w http.ResponseWriter, serverCtx context.Context, srv *service.Service, links []string, opts service.Options, logger *zap.Logger) {
+	job, err := srv.Submit(serverCtx, links, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAppStopped):
+			_ = writeResponse(w, http.StatusAccepted, job, logger)
+
+		case errors.Is(err, service.ErrQueueFull):
+			http.Error(w, "job queue is full", http.StatusServiceUnavailable)
+			logger.Warn("job queue is full")
+
+		default:
+			http.Error(w, "failed to submit links", http.StatusInternalServerError)
+			logger.Error("failed to submit links", zap.Error(err))
+		}
+
+		return
+	}
+
+	_ = writeResponse(w, http.StatusAccepted, job, logger)
+}
+
+// availabilityPolicy applies the fields set in req to the default policy.
+func availabilityPolicy(policy domain.Availability, req *availabilityRequest) (domain.Availability, error) {
+	if req.Statuses != nil {
+		statuses, err := service.ParseStatusRanges(*req.Statuses)
+		if err != nil {
+			return policy, err
+		}
+
+		policy.Statuses = statuses
+	}
+
+	if req.FollowRedirects != nil {
+		policy.FollowRedirects = *req.FollowRedirects
+	}
+
+	if req.AuthAvailable != nil {
+		policy.AuthAvailable = *req.AuthAvailable
+	}
+
+	return policy, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, body any, logger *zap.Logger) error {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 
-	err := json.NewEncoder(w).Encode(rec)
+	err := json.NewEncoder(w).Encode(body)
 	if err != nil {
 		logger.Warn("failed to encode response", zap.Error(err))
 	}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository/memory"
+	"link-service/internal/service"
+)
+
+func newTestService(t *testing.T) *service.Service {
+	t.Helper()
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	echo := service.CheckerFunc(func(ctx context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+		if strings.Contains(link, "slow") {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return domain.LinkResult{}, ctx.Err()
+			}
+		}
+
+		return domain.LinkResult{Method: "echo", FinalURL: link}, nil
+	})
+
+	cfg := &service.Config{PingTimeout: 5 * time.Second, Workers: 2, MaxConnections: 2, JobWorkers: 1, QueueSize: 10, CompleteTimedOut: true}
+
+	return service.New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), service.WithChecker("echo", echo))
+}
+
+func TestProcessLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		check      func(t *testing.T, body []byte)
+	}{
+		{
+			name:       "malformed body",
+			body:       `{"links":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid callback url",
+			body:       `{"links":["echo://up.test"],"callback_url":"ftp://hooks.test"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid links",
+			body:       `{"links":["echo://up.test","","gopher://up.test"]}`,
+			wantStatus: http.StatusBadRequest,
+			check: func(t *testing.T, body []byte) {
+				var resp validationResponse
+				assert.NoError(t, json.Unmarshal(body, &resp))
+				if assert.Len(t, resp.Errors, 2) {
+					assert.Equal(t, 1, resp.Errors[0].Index)
+					assert.Equal(t, 2, resp.Errors[1].Index)
+					assert.Equal(t, "gopher://up.test", resp.Errors[1].Link)
+				}
+			},
+		},
+		{
+			name:       "invalid availability",
+			body:       `{"links":["echo://up.test"],"availability":{"statuses":"600-700"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "checked",
+			body:       `{"links":["echo://up.test","echo://UP.test"]}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, body []byte) {
+				var rec domain.Record
+				assert.NoError(t, json.Unmarshal(body, &rec))
+				assert.Equal(t, int64(1), rec.ID)
+				if assert.Len(t, rec.Links, 2) {
+					assert.Equal(t, "echo://UP.test", rec.Links[1].Link)
+					assert.Equal(t, domain.StatusAvailable, rec.Links[1].Result.Status)
+				}
+			},
+		},
+		{
+			name:       "request timeout",
+			body:       `{"links":["echo://up.test","echo://slow.test"]}`,
+			wantStatus: http.StatusAccepted,
+			check: func(t *testing.T, body []byte) {
+				var rec domain.Record
+				assert.NoError(t, json.Unmarshal(body, &rec))
+				assert.Equal(t, int64(1), rec.ID)
+				if assert.Len(t, rec.Links, 2) {
+					assert.Equal(t, domain.StatusAvailable, rec.Links[0].Result.Status)
+					assert.Equal(t, domain.StatusPending, rec.Links[1].Result.Status, "links still being checked must be pending")
+				}
+			},
+		},
+		{
+			name:       "async",
+			body:       `{"links":["echo://up.test"],"async":true}`,
+			wantStatus: http.StatusAccepted,
+			check: func(t *testing.T, body []byte) {
+				var job domain.Job
+				assert.NoError(t, json.Unmarshal(body, &job))
+				assert.Equal(t, int64(1), job.ID)
+				assert.Equal(t, domain.JobStateQueued, job.State)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ProcessLinks(context.Background(), newTestService(t), 200*time.Millisecond, zap.NewNop())
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/links", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.check != nil {
+				tt.check(t, w.Body.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"link-service/internal/domain"
+)
+
+const (
+	chartLabelWidth = 60.0
+	chartValueWidth = 20.0
+	chartRowHeight  = 6.0
+	chartBarHeight  = 4.0
+)
+
+type chartPhase struct {
+	name    string
+	r, g, b int
+}
+
+// chartPhases are the segments of a timing bar, in order.
+var chartPhases = []chartPhase{
+	{name: "dns", r: 91, g: 155, b: 213},
+	{name: "connect", r: 237, g: 125, b: 49},
+	{name: "tls", r: 165, g: 165, b: 165},
+	{name: "wait", r: 255, g: 192, b: 0},
+	{name: "transfer", r: 112, g: 173, b: 71},
+}
+
+// phaseDurations splits t into the durations of chartPhases. The wait phase
+// is the time to first byte not spent in the connection phases.
+func phaseDurations(t *domain.Timing) []int64 {
+	wait := max(t.TTFBMs-t.DNSMs-t.ConnectMs-t.TLSMs, 0)
+	transfer := max(t.TotalMs-t.TTFBMs, 0)
+
+	return []int64{t.DNSMs, t.ConnectMs, t.TLSMs, wait, transfer}
+}
+
+// drawTimingChart plots a stacked bar of the timing phases of every link of
+// rec that has a timing, in submission order and scaled to the slowest one.
+// Links checked together get a single bar.
+func drawTimingChart(pdf *gofpdf.Fpdf, rec *domain.Record) {
+	if rec.Timing == nil {
+		return
+	}
+
+	var entries []domain.LinkEntry
+	seen := make(map[string]bool, len(rec.Links))
+	for _, entry := range rec.Links {
+		if entry.Result.Timing != nil && !seen[entry.Normalized] {
+			seen[entry.Normalized] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	_, bottom := pdf.GetAutoPageBreak()
+	barArea := pageWidth - left - right - chartLabelWidth - chartValueWidth
+
+	pdf.CellFormat(0, chartRowHeight, fmt.Sprintf("Timing, ms (mean %d, max %d, slowest %s)",
+		rec.Timing.Mean.TotalMs, rec.Timing.Max.TotalMs, rec.Timing.Slowest), "", 1, "", false, 0, "")
+	drawChartLegend(pdf)
+
+	scale := barArea / float64(max(rec.Timing.Max.TotalMs, 1))
+
+	for _, entry := range entries {
+		timing := entry.Result.Timing
+
+		if pdf.GetY()+chartRowHeight > pageHeight-bottom {
+			pdf.AddPage()
+		}
+
+		y := pdf.GetY()
+		pdf.CellFormat(chartLabelWidth, chartRowHeight, fitString(pdf, entry.Link, chartLabelWidth), "", 0, "", false, 0, "")
+
+		x := left + chartLabelWidth
+		for i, d := range phaseDurations(timing) {
+			width := float64(d) * scale
+			if width <= 0 {
+				continue
+			}
+
+			phase := chartPhases[i]
+			pdf.SetFillColor(phase.r, phase.g, phase.b)
+			pdf.Rect(x, y+(chartRowHeight-chartBarHeight)/2, width, chartBarHeight, "F")
+			x += width
+		}
+
+		pdf.SetXY(left+chartLabelWidth+barArea, y)
+		pdf.CellFormat(chartValueWidth, chartRowHeight, fmt.Sprintf("%d", timing.TotalMs), "", 1, "R", false, 0, "")
+	}
+}
+
+func drawChartLegend(pdf *gofpdf.Fpdf) {
+	left, _, _, _ := pdf.GetMargins()
+	y := pdf.GetY()
+	x := left
+
+	for _, phase := range chartPhases {
+		pdf.SetFillColor(phase.r, phase.g, phase.b)
+		pdf.Rect(x, y+(chartRowHeight-chartBarHeight)/2, chartBarHeight, chartBarHeight, "F")
+		x += chartBarHeight + 1
+
+		pdf.SetXY(x, y)
+		pdf.CellFormat(25, chartRowHeight, phase.name, "", 0, "", false, 0, "")
+		x += 25
+	}
+
+	pdf.Ln(chartRowHeight)
+}
+
+// fitString shortens s with an ellipsis until it fits into width.
+func fitString(pdf *gofpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
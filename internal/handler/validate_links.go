@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"link-service/internal/service"
+)
+
+// linkError is the validation error of a single submitted link.
+type linkError struct {
+	Index int    `json:"index"`
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+type validationResponse struct {
+	Errors []linkError `json:"errors"`
+}
+
+// validateLinks reports the submitted links that cannot be normalized, along
+// with their index.
+func validateLinks(srv *service.Service, links []string) []linkError {
+	var errs []linkError
+	for i, link := range links {
+		_, err := srv.NormalizeLink(link)
+		if err != nil {
+			errs = append(errs, linkError{Index: i, Link: link, Error: err.Error()})
+		}
+	}
+
+	return errs
+}
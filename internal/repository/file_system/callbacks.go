@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+func (s *Storage) SaveCallback(callback *domain.Callback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := encodeLine(callback)
+	if err != nil {
+		s.logger.Error("failed to marshal callback", zap.Error(err))
+		return fmt.Errorf("failed to marshal callback: %w", err)
+	}
+
+	_, err = appendLine(s.callbacksPath, line, s.fsync)
+	if err != nil {
+		s.logger.Error("failed to write callback", zap.String("path", s.callbacksPath), zap.Error(err))
+		return fmt.Errorf("failed to write callback: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) LoadCallbacks() ([]domain.Callback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var callbacks []domain.Callback
+	err := readLines(s.callbacksPath, func(line []byte) error {
+		var callback domain.Callback
+		err := json.Unmarshal(line, &callback)
+		if err != nil {
+			return fmt.Errorf("failed to decode callback: %w", err)
+		}
+
+		// A callback saved again is appended, its latest line wins.
+		i := slices.IndexFunc(callbacks, func(saved domain.Callback) bool {
+			return saved.ID == callback.ID
+		})
+		if i >= 0 {
+			callbacks[i] = callback
+		} else {
+			callbacks = append(callbacks, callback)
+		}
+
+		return nil
+	}, func(line []byte, err error) {
+		s.logger.Warn("skipping corrupted callback", zap.ByteString("line", line), zap.Error(err))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load callbacks: %w", err)
+	}
+
+	return callbacks, nil
+}
+
+func (s *Storage) DeleteCallback(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := removeLines(s.callbacksPath, id, s.fsync)
+	if err != nil {
+		s.logger.Error("failed to delete callback", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete callback: %w", err)
+	}
+
+	return nil
+}
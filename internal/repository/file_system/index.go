@@ -0,0 +1,302 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// indexEntrySize is the size of an encoded index entry: links_num, offset and
+// length of the record line, each a little endian int64.
+const indexEntrySize = 24
+
+// indexEntry locates a record line, without its trailing newline, in the data
+// file.
+type indexEntry struct {
+	offset int64
+	length int64
+}
+
+// index maps links_num to the position of the record in the data file. It is
+// kept in memory and mirrored to an append-only file, so it does not have to
+// be rebuilt on every start.
+type index struct {
+	path    string
+	entries map[int64]indexEntry
+	// end is the offset in the data file up to which records are indexed.
+	end int64
+	// maxID is the highest indexed links_num.
+	maxID int64
+	// broken is set once an entry could not be written, so that the file is
+	// rebuilt on the next start instead of silently missing records.
+	broken bool
+}
+
+// openIndex loads the index file at path for the data file at dataPath. A
+// missing or stale index is rebuilt, records appended to the data file since
+// the index was last written are indexed.
+func openIndex(path string, dataPath string) (*index, error) {
+	idx, ok, err := loadIndex(path, dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return rebuildIndex(path, dataPath)
+	}
+
+	err = idx.indexTail(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// loadIndex reads the index file and reports whether it is consistent with
+// the data file.
+func loadIndex(path string, dataPath string) (*index, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+
+		return nil, false, fmt.Errorf("failed to read index file: %s: %w", path, err)
+	}
+
+	if len(data)%indexEntrySize != 0 {
+		return nil, false, nil
+	}
+
+	stat, err := os.Stat(dataPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to stat file: %s: %w", dataPath, err)
+	}
+
+	idx := &index{
+		path:    path,
+		entries: make(map[int64]indexEntry, len(data)/indexEntrySize),
+	}
+
+	var (
+		lastID    int64
+		lastEntry indexEntry
+	)
+	for buf := data; len(buf) > 0; buf = buf[indexEntrySize:] {
+		id := int64(binary.LittleEndian.Uint64(buf[0:8]))
+		entry := indexEntry{
+			offset: int64(binary.LittleEndian.Uint64(buf[8:16])),
+			length: int64(binary.LittleEndian.Uint64(buf[16:24])),
+		}
+
+		if entry.offset < 0 || entry.length < 0 || entry.offset+entry.length+1 > stat.Size() {
+			return nil, false, nil
+		}
+
+		idx.put(id, entry)
+		lastID, lastEntry = id, entry
+	}
+
+	if len(idx.entries) == 0 {
+		return idx, stat.Size() == 0, nil
+	}
+
+	// The last indexed line must still hold the same record, otherwise the
+	// data file was replaced or rewritten.
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open file: %s: %w", dataPath, err)
+	}
+	defer file.Close()
+
+	id, err := readRecordID(file, lastEntry)
+	if err != nil || id != lastID {
+		return nil, false, nil
+	}
+
+	return idx, true, nil
+}
+
+// rebuildIndex indexes the whole data file and replaces the index file.
+func rebuildIndex(path string, dataPath string) (*index, error) {
+	idx := &index{
+		path:    path,
+		entries: make(map[int64]indexEntry),
+	}
+
+	var buf bytes.Buffer
+	err := scanRecords(dataPath, 0, func(id int64, entry indexEntry) {
+		idx.put(id, entry)
+		buf.Write(encodeIndexEntry(id, entry))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tmpPath := path + ".tmp"
+
+	err = os.WriteFile(tmpPath, buf.Bytes(), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write index file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace index file: %s: %w", path, err)
+	}
+
+	return idx, nil
+}
+
+// indexTail indexes the records appended to the data file after idx.end.
+func (idx *index) indexTail(dataPath string) error {
+	var buf bytes.Buffer
+	err := scanRecords(dataPath, idx.end, func(id int64, entry indexEntry) {
+		idx.put(id, entry)
+		buf.Write(encodeIndexEntry(id, entry))
+	})
+	if err != nil {
+		return err
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	return idx.write(buf.Bytes())
+}
+
+// add indexes the record line written at entry.
+func (idx *index) add(id int64, entry indexEntry) error {
+	idx.put(id, entry)
+
+	if idx.broken {
+		return nil
+	}
+
+	err := idx.write(encodeIndexEntry(id, entry))
+	if err != nil {
+		idx.broken = true
+		_ = os.Remove(idx.path)
+		return err
+	}
+
+	return nil
+}
+
+// put indexes entry in memory. The first record with a given links_num wins.
+func (idx *index) put(id int64, entry indexEntry) {
+	if _, ok := idx.entries[id]; !ok {
+		idx.entries[id] = entry
+	}
+
+	idx.end = max(idx.end, entry.offset+entry.length+1)
+	idx.maxID = max(idx.maxID, id)
+}
+
+func (idx *index) lookup(id int64) (indexEntry, bool) {
+	entry, ok := idx.entries[id]
+	return entry, ok
+}
+
+func (idx *index) write(data []byte) error {
+	file, err := os.OpenFile(idx.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open index file: %s: %w", idx.path, err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write index file: %s: %w", idx.path, err)
+	}
+
+	return nil
+}
+
+// scanRecords calls fn for every complete record line of the data file
+// starting at offset. Lines that are not valid records or fail checksum
+// verification are skipped.
+func scanRecords(dataPath string, offset int64, fn func(id int64, entry indexEntry)) error {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s: %w", dataPath, err)
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to seek file: %s: %w", dataPath, err)
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read file: %s: %w", dataPath, err)
+		}
+
+		entry := indexEntry{
+			offset: offset,
+			length: int64(len(line) - 1),
+		}
+		offset += int64(len(line))
+
+		var rec struct {
+			ID int64 `json:"links_num"`
+		}
+
+		if verifyLine(line) == nil && json.Unmarshal(line, &rec) == nil {
+			fn(rec.ID, entry)
+		}
+	}
+}
+
+// readLine returns the record line located by entry.
+func readLine(file *os.File, entry indexEntry) ([]byte, error) {
+	line := make([]byte, entry.length)
+
+	_, err := file.ReadAt(line, entry.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func readRecordID(file *os.File, entry indexEntry) (int64, error) {
+	line, err := readLine(file, entry)
+	if err != nil {
+		return 0, err
+	}
+
+	var rec struct {
+		ID int64 `json:"links_num"`
+	}
+
+	err = json.Unmarshal(line, &rec)
+	if err != nil {
+		return 0, err
+	}
+
+	return rec.ID, nil
+}
+
+func encodeIndexEntry(id int64, entry indexEntry) []byte {
+	buf := make([]byte, indexEntrySize)
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(id))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(entry.offset))
+	binary.LittleEndian.PutUint64(buf[16:24], uint64(entry.length))
+
+	return buf
+}
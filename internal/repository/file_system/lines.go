@@ -0,0 +1,291 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Every line written by the storage ends with a checksum field holding the
+// CRC32 (Castagnoli) of the JSON object without it, e.g.
+// {"links":{...},"links_num":1,"crc32":"1a2b3c4d"}. Lines written before
+// checksums were introduced carry none and are only checked to be valid JSON.
+const (
+	checksumPrefix = `"crc32":"`
+	// checksumSuffixLen is the length of `"crc32":"xxxxxxxx"}`.
+	checksumSuffixLen = len(checksumPrefix) + 8 + 2
+)
+
+var (
+	errInvalidLine      = errors.New("invalid line")
+	errChecksumMismatch = errors.New("checksum mismatch")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// encodeLine marshals v, which must encode as a JSON object, into a
+// checksummed line terminated by a newline.
+func encodeLine(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2 || data[0] != '{' || data[len(data)-1] != '}' {
+		return nil, fmt.Errorf("%w: not a json object", errInvalidLine)
+	}
+
+	line := make([]byte, 0, len(data)+checksumSuffixLen+2)
+	line = append(line, data[:len(data)-1]...)
+	if len(data) > 2 {
+		line = append(line, ',')
+	}
+
+	line = fmt.Appendf(line, "%s%08x\"}\n", checksumPrefix, crc32.Checksum(data, crcTable))
+
+	return line, nil
+}
+
+// verifyLine checks that line, with or without its trailing newline, is a
+// valid JSON object whose checksum, if present, matches.
+func verifyLine(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if !json.Valid(line) {
+		return errInvalidLine
+	}
+
+	n := len(line)
+	if n < checksumSuffixLen+1 || !bytes.HasPrefix(line[n-checksumSuffixLen:], []byte(checksumPrefix)) {
+		return nil
+	}
+
+	want, err := strconv.ParseUint(string(line[n-10:n-2]), 16, 32)
+	if err != nil {
+		return errChecksumMismatch
+	}
+
+	var data []byte
+	switch line[n-checksumSuffixLen-1] {
+	case ',':
+		data = make([]byte, 0, n-checksumSuffixLen)
+		data = append(data, line[:n-checksumSuffixLen-1]...)
+		data = append(data, '}')
+	case '{':
+		data = []byte("{}")
+	default:
+		return nil
+	}
+
+	if crc32.Checksum(data, crcTable) != uint32(want) {
+		return errChecksumMismatch
+	}
+
+	return nil
+}
+
+// appendLine appends line to the file at path and returns the offset it was
+// written at. With fsync the data is flushed to disk before returning.
+func appendLine(path string, line []byte, fsync bool) (int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to seek file: %s: %w", path, err)
+	}
+
+	_, err = file.Write(line)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write file: %s: %w", path, err)
+	}
+
+	if fsync {
+		err = file.Sync()
+		if err != nil {
+			return 0, fmt.Errorf("failed to sync file: %s: %w", path, err)
+		}
+	}
+
+	return offset, nil
+}
+
+// readLines calls fn for every line of the file at path. Lines that fail
+// verification are passed to onInvalid instead.
+func readLines(path string, fn func(line []byte) error, onInvalid func(line []byte, err error)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			verifyErr := verifyLine(line)
+			if verifyErr != nil {
+				onInvalid(line, verifyErr)
+			} else if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read file: %s: %w", path, err)
+		}
+	}
+}
+
+// removeLines rewrites the JSONL file at path without the lines whose
+// links_num equals id. The file is replaced atomically.
+func removeLines(path string, id int64, fsync bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %s: %w", path, err)
+	}
+
+	var kept []byte
+	for line := range bytes.Lines(data) {
+		var entry struct {
+			ID int64 `json:"links_num"`
+		}
+
+		err = json.Unmarshal(line, &entry)
+		if err == nil && entry.ID == id {
+			continue
+		}
+
+		kept = append(kept, line...)
+	}
+
+	return replaceFile(path, kept, fsync)
+}
+
+// replaceFile atomically replaces the contents of the file at path.
+func replaceFile(path string, data []byte, fsync bool) error {
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s: %w", tmpPath, err)
+	}
+
+	_, err = file.Write(data)
+	if err == nil && fsync {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to replace file: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// recoverTail truncates a torn last line left by a crash in the middle of an
+// append: a trailing fragment without a newline, or a last line that fails
+// verification. It returns the number of bytes removed.
+func recoverTail(path string) (int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %s: %w", path, err)
+	}
+
+	size := stat.Size()
+	if size == 0 {
+		return 0, nil
+	}
+
+	// Everything after the last newline is a fragment of an unfinished append.
+	end, err := lineStart(file, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if end > 0 {
+		start, err := lineStart(file, end-1)
+		if err != nil {
+			return 0, err
+		}
+
+		line := make([]byte, end-start)
+		_, err = file.ReadAt(line, start)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %s: %w", path, err)
+		}
+
+		if verifyLine(line) != nil {
+			end = start
+		}
+	}
+
+	if end == size {
+		return 0, nil
+	}
+
+	err = file.Truncate(end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to truncate file: %s: %w", path, err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return 0, fmt.Errorf("failed to sync file: %s: %w", path, err)
+	}
+
+	return size - end, nil
+}
+
+// lineStart returns the offset of the first byte after the last newline
+// located before pos, or 0 if there is none.
+func lineStart(file *os.File, pos int64) (int64, error) {
+	const chunkSize = 4096
+
+	buf := make([]byte, chunkSize)
+	for pos > 0 {
+		from := max(pos-chunkSize, 0)
+		chunk := buf[:pos-from]
+
+		_, err := file.ReadAt(chunk, from)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return from + int64(i) + 1, nil
+		}
+
+		pos = from
+	}
+
+	return 0, nil
+}
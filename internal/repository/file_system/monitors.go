@@ -0,0 +1,162 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// Monitors are few and change on every run, so the monitors file is
+// rewritten as a whole instead of being appended to.
+
+// monitorLine is a line of the monitors file. If the monitor with the highest
+// ID is deleted, a tombstone of it is kept, so that its ID is not reused.
+type monitorLine struct {
+	domain.Monitor
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+func (s *Storage) SaveMonitor(monitor *domain.Monitor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.readMonitors()
+	if err != nil {
+		s.logger.Error("failed to read monitors", zap.String("path", s.monitorsPath), zap.Error(err))
+		return err
+	}
+
+	i := slices.IndexFunc(lines, func(line monitorLine) bool {
+		return line.ID == monitor.ID
+	})
+	if i >= 0 {
+		lines[i] = monitorLine{Monitor: *monitor}
+	} else {
+		lines = append(lines, monitorLine{Monitor: *monitor})
+	}
+
+	err = s.writeMonitors(lines)
+	if err != nil {
+		s.logger.Error("failed to write monitor", zap.Int64("id", monitor.ID), zap.Error(err))
+		return fmt.Errorf("failed to write monitor: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) LoadMonitors() ([]domain.Monitor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.readMonitors()
+	if err != nil {
+		return nil, err
+	}
+
+	var monitors []domain.Monitor
+	for _, line := range lines {
+		if !line.Deleted {
+			monitors = append(monitors, line.Monitor)
+		}
+	}
+
+	return monitors, nil
+}
+
+func (s *Storage) LoadLastMonitorID() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.readMonitors()
+	if err != nil {
+		return 0, err
+	}
+
+	var lastID int64
+	for _, line := range lines {
+		lastID = max(lastID, line.ID)
+	}
+
+	return lastID, nil
+}
+
+func (s *Storage) DeleteMonitor(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.readMonitors()
+	if err != nil {
+		s.logger.Error("failed to read monitors", zap.String("path", s.monitorsPath), zap.Error(err))
+		return err
+	}
+
+	for i := range lines {
+		if lines[i].ID == id {
+			lines[i] = monitorLine{Monitor: domain.Monitor{ID: id}, Deleted: true}
+		}
+	}
+
+	err = s.writeMonitors(lines)
+	if err != nil {
+		s.logger.Error("failed to delete monitor", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete monitor: %w", err)
+	}
+
+	return nil
+}
+
+// readMonitors loads the monitors file, tombstones included. s.mu must be
+// held.
+func (s *Storage) readMonitors() ([]monitorLine, error) {
+	var lines []monitorLine
+	err := readLines(s.monitorsPath, func(data []byte) error {
+		var line monitorLine
+		err := json.Unmarshal(data, &line)
+		if err != nil {
+			return fmt.Errorf("failed to decode monitor: %w", err)
+		}
+
+		lines = append(lines, line)
+		return nil
+	}, func(line []byte, err error) {
+		s.logger.Warn("skipping corrupted monitor", zap.ByteString("line", line), zap.Error(err))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load monitors: %w", err)
+	}
+
+	return lines, nil
+}
+
+// writeMonitors replaces the monitors file with the monitors of lines. Of
+// the tombstones, only the one above all monitors is kept. s.mu must be
+// held.
+func (s *Storage) writeMonitors(lines []monitorLine) error {
+	var lastID, lastLiveID int64
+	for _, line := range lines {
+		lastID = max(lastID, line.ID)
+		if !line.Deleted {
+			lastLiveID = max(lastLiveID, line.ID)
+		}
+	}
+
+	var data []byte
+	for _, line := range lines {
+		if line.Deleted && (line.ID != lastID || lastID == lastLiveID) {
+			continue
+		}
+
+		encoded, err := encodeLine(line)
+		if err != nil {
+			return fmt.Errorf("failed to marshal monitor: %w", err)
+		}
+
+		data = append(data, encoded...)
+	}
+
+	return replaceFile(s.monitorsPath, data, s.fsync)
+}
@@ -1,34 +1,58 @@
 package filesystem
 
 import (
-	"bufio"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"go.uber.org/zap"
 
 	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+const (
+	fsyncAlways = "always"
+	fsyncNever  = "never"
 )
 
 type Config struct {
-	DirPath      string `env:"STORAGE_DIR_PATH" env-required:"true"`
-	FileName     string `env:"STORAGE_FILE_NAME" env-required:"true"`
-	TempFileName string `env:"STORAGE_TEMP_FILE_NAME" env-required:"true"`
+	DirPath           string `env:"STORAGE_DIR_PATH" env-required:"true"`
+	FileName          string `env:"STORAGE_FILE_NAME" env-default:"data.json"`
+	TempFileName      string `env:"STORAGE_TEMP_FILE_NAME" env-default:"temp.json"`
+	CallbacksFileName string `env:"STORAGE_CALLBACKS_FILE_NAME" env-default:"callbacks.json"`
+	MonitorsFileName  string `env:"STORAGE_MONITORS_FILE_NAME" env-default:"monitors.json"`
+	IndexFileName     string `env:"STORAGE_INDEX_FILE_NAME" env-default:"data.idx"`
+	// Fsync is either "always", to flush every write to disk before it is
+	// acknowledged, or "never", to leave it to the OS.
+	Fsync string `env:"STORAGE_FSYNC" env-default:"always"`
 }
 
 type Storage struct {
-	mu       *sync.Mutex
-	path     string
-	tempPath string
-	logger   *zap.Logger
+	mu            *sync.Mutex
+	path          string
+	tempPath      string
+	callbacksPath string
+	monitorsPath  string
+	index         *index
+	fsync         bool
+	logger        *zap.Logger
 }
 
 func New(cfg *Config, logger *zap.Logger) (*Storage, error) {
+	var fsync bool
+	switch cfg.Fsync {
+	case fsyncAlways:
+		fsync = true
+	case fsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy: %q", cfg.Fsync)
+	}
+
 	err := os.MkdirAll(cfg.DirPath, 0755)
 	if err != nil {
 		logger.Error("failed to create dir", zap.String("dir_path", cfg.DirPath), zap.Error(err))
@@ -60,13 +84,70 @@ func New(cfg *Config, logger *zap.Logger) (*Storage, error) {
 
 	defer tempFile.Close()
 
-	logger.Info("files created", zap.String("file", filePath), zap.String("temp_path", tempFilePath))
+	callbacksFilePath := filepath.Join(cfg.DirPath, cfg.CallbacksFileName)
+
+	callbacksFile, err := os.OpenFile(callbacksFilePath,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0644,
+	)
+	if err != nil {
+		logger.Error("failed to create callbacks file", zap.String("file_name", cfg.CallbacksFileName), zap.Error(err))
+		return nil, fmt.Errorf("failed to create callbacks file: %s: %w", cfg.CallbacksFileName, err)
+	}
+
+	defer callbacksFile.Close()
+
+	monitorsFilePath := filepath.Join(cfg.DirPath, cfg.MonitorsFileName)
+
+	monitorsFile, err := os.OpenFile(monitorsFilePath,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0644,
+	)
+	if err != nil {
+		logger.Error("failed to create monitors file", zap.String("file_name", cfg.MonitorsFileName), zap.Error(err))
+		return nil, fmt.Errorf("failed to create monitors file: %s: %w", cfg.MonitorsFileName, err)
+	}
+
+	defer monitorsFile.Close()
+
+	for _, path := range []string{filePath, tempFilePath, callbacksFilePath, monitorsFilePath} {
+		removed, err := recoverTail(path)
+		if err != nil {
+			logger.Error("failed to recover file", zap.String("path", path), zap.Error(err))
+			return nil, fmt.Errorf("failed to recover file: %s: %w", path, err)
+		}
+
+		if removed > 0 {
+			logger.Warn("truncated torn last line", zap.String("path", path), zap.Int64("bytes", removed))
+		}
+	}
+
+	indexFilePath := filepath.Join(cfg.DirPath, cfg.IndexFileName)
+
+	idx, err := openIndex(indexFilePath, filePath)
+	if err != nil {
+		logger.Error("failed to open index", zap.String("file_name", cfg.IndexFileName), zap.Error(err))
+		return nil, fmt.Errorf("failed to open index: %s: %w", cfg.IndexFileName, err)
+	}
+
+	logger.Info("files created",
+		zap.String("file", filePath),
+		zap.String("temp_path", tempFilePath),
+		zap.String("callbacks_path", callbacksFilePath),
+		zap.String("monitors_path", monitorsFilePath),
+		zap.String("index_path", indexFilePath),
+		zap.Int("indexed_records", len(idx.entries)),
+	)
 
 	return &Storage{
-		mu:       &sync.Mutex{},
-		path:     filePath,
-		tempPath: tempFilePath,
-		logger:   logger,
+		mu:            &sync.Mutex{},
+		path:          filePath,
+		tempPath:      tempFilePath,
+		callbacksPath: callbacksFilePath,
+		monitorsPath:  monitorsFilePath,
+		index:         idx,
+		fsync:         fsync,
+		logger:        logger,
 	}, nil
 }
 
@@ -74,27 +155,23 @@ func (s *Storage) SaveRecord(record *domain.Record) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
-		return fmt.Errorf("failed to open file: %s: %w", s.path, err)
-	}
-	defer file.Close()
-
-	data, err := json.Marshal(record)
+	line, err := encodeLine(record)
 	if err != nil {
 		s.logger.Error("failed to marshal record", zap.Error(err))
 		return fmt.Errorf("failed to marshal record: %w", err)
 	}
 
-	data = append(data, '\n')
-
-	_, err = file.Write(data)
+	offset, err := appendLine(s.path, line, s.fsync)
 	if err != nil {
-		s.logger.Error("failed to write record", zap.Error(err))
+		s.logger.Error("failed to write record", zap.String("path", s.path), zap.Error(err))
 		return fmt.Errorf("failed to write record: %w", err)
 	}
 
+	err = s.index.add(record.ID, indexEntry{offset: offset, length: int64(len(line) - 1)})
+	if err != nil {
+		s.logger.Error("failed to index record, index will be rebuilt on next start", zap.Int64("id", record.ID), zap.Error(err))
+	}
+
 	s.logger.Info("successfully wrote record")
 	return nil
 }
@@ -103,24 +180,15 @@ func (s *Storage) SaveTempRecord(record *domain.Record) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	tempFile, err := os.OpenFile(s.tempPath, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		s.logger.Error("failed to open temp file", zap.String("path", s.tempPath), zap.Error(err))
-		return fmt.Errorf("failed to open temp file: %s: %w", s.tempPath, err)
-	}
-	defer tempFile.Close()
-
-	data, err := json.Marshal(record)
+	line, err := encodeLine(record)
 	if err != nil {
 		s.logger.Error("failed to marshal temp record", zap.Error(err))
 		return fmt.Errorf("failed to marshal temp record: %w", err)
 	}
 
-	data = append(data, '\n')
-
-	_, err = tempFile.Write(data)
+	_, err = appendLine(s.tempPath, line, s.fsync)
 	if err != nil {
-		s.logger.Error("failed to write temp record", zap.Error(err))
+		s.logger.Error("failed to write temp record", zap.String("path", s.tempPath), zap.Error(err))
 		return fmt.Errorf("failed to write temp record: %w", err)
 	}
 
@@ -132,36 +200,57 @@ func (s *Storage) LoadTempRecords() ([]domain.Record, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	tempFile, err := os.Open(s.tempPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open temp file: %w", err)
-	}
-	defer tempFile.Close()
-
+	// Temp records are replaced by appending the new version, so the last
+	// line saved under an ID wins.
 	var records []domain.Record
-	decoder := json.NewDecoder(tempFile)
-
-	for {
+	positions := make(map[int64]int)
+	err := readLines(s.tempPath, func(line []byte) error {
 		var rec domain.Record
-		err = decoder.Decode(&rec)
+		err := json.Unmarshal(line, &rec)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
+			return fmt.Errorf("failed to decode temp record: %w", err)
+		}
 
-			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+		if i, ok := positions[rec.ID]; ok {
+			records[i] = rec
+			return nil
 		}
 
+		positions[rec.ID] = len(records)
 		records = append(records, rec)
+		return nil
+	}, func(line []byte, err error) {
+		s.logger.Warn("skipping corrupted temp record", zap.ByteString("line", line), zap.Error(err))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
 	}
 
 	return records, nil
 }
 
+func (s *Storage) DeleteTempRecord(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := removeLines(s.tempPath, id, s.fsync)
+	if err != nil {
+		s.logger.Error("failed to delete temp record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete temp record: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	entry, ok := s.index.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
 	file, err := os.Open(s.path)
 	if err != nil {
 		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
@@ -169,83 +258,106 @@ func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var rec domain.Record
-		err = json.Unmarshal(scanner.Bytes(), &rec)
-		if err != nil {
-			continue
-		}
-
-		if rec.ID == id {
-			return &rec, nil
-		}
-	}
-
-	err = scanner.Err()
+	rec, err := readRecord(file, entry)
 	if err != nil {
-		s.logger.Error("failed to scan file", zap.String("path", s.path), zap.Error(err))
-		return nil, fmt.Errorf("failed to scan file: %s: %w", s.path, err)
+		s.logger.Error("failed to read record", zap.Int64("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to read record: %d: %w", id, err)
 	}
 
-	return nil, fmt.Errorf("record with ID %d not found", id)
+	return rec, nil
 }
 
-func (s *Storage) ClearTempFile() error {
+// GetRecords looks up records by their IDs. IDs without a record, or whose
+// record cannot be read, are missing from the result.
+func (s *Storage) GetRecords(ids []int64) (map[int64]*domain.Record, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	err := os.WriteFile(s.tempPath, []byte{}, 0644)
+	file, err := os.Open(s.path)
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	records := make(map[int64]*domain.Record, len(ids))
+	for _, id := range ids {
+		entry, ok := s.index.lookup(id)
+		if !ok {
+			continue
+		}
+
+		rec, err := readRecord(file, entry)
+		if err != nil {
+			s.logger.Error("skipping unreadable record", zap.Int64("id", id), zap.Error(err))
+			continue
+		}
+
+		records[id] = rec
+	}
 
-	return err
+	return records, nil
 }
 
-func (s *Storage) LoadLastLinksNum() int64 {
+// FindRecords reads every indexed record, the data file has no index on
+// their contents. Unreadable records are skipped.
+func (s *Storage) FindRecords(filter repository.RecordFilter) ([]domain.Record, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	file, err := os.Open(s.path)
 	if err != nil {
 		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
-		return 0
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
 	}
 	defer file.Close()
 
-	stat, err := file.Stat()
-	if err != nil {
-		s.logger.Error("failed to stat file", zap.Error(err))
-		return 0
-	}
-
-	if stat.Size() == 0 {
-		return 0
-	}
-
-	buf := make([]byte, 1)
-	var lastLine []byte
-	pos := stat.Size() - 1
-
-	for pos >= 0 {
-		_, err = file.ReadAt(buf, pos)
+	records := []domain.Record{}
+	for _, id := range slices.Sorted(maps.Keys(s.index.entries)) {
+		rec, err := readRecord(file, s.index.entries[id])
 		if err != nil {
-			s.logger.Error("failed to read file", zap.Error(err))
-			return 0
+			s.logger.Error("skipping unreadable record", zap.Int64("id", id), zap.Error(err))
+			continue
+		}
+
+		if !filter.Matches(rec) {
+			continue
 		}
 
-		if buf[0] == '\n' && pos != stat.Size()-1 {
+		records = append(records, *rec)
+		if len(records) == filter.Limit {
 			break
 		}
+	}
+
+	return records, nil
+}
+
+// readRecord reads and decodes the record at entry.
+func readRecord(file *os.File, entry indexEntry) (*domain.Record, error) {
+	line, err := readLine(file, entry)
+	if err != nil {
+		return nil, err
+	}
 
-		lastLine = append([]byte{buf[0]}, lastLine...)
-		pos--
+	err = verifyLine(line)
+	if err != nil {
+		return nil, fmt.Errorf("corrupted record: %w", err)
 	}
 
 	var rec domain.Record
-	err = json.Unmarshal(lastLine, &rec)
+	err = json.Unmarshal(line, &rec)
 	if err != nil {
-		s.logger.Error("failed to unmarshal last record", zap.Error(err))
-		return 0
+		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
 	}
 
-	return rec.ID
+	return &rec, nil
+}
+
+// LoadLastLinksNum returns the highest links_num among the valid records.
+func (s *Storage) LoadLastLinksNum() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.index.maxID
 }
@@ -0,0 +1,240 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	return &Config{
+		DirPath:           t.TempDir(),
+		FileName:          "data.json",
+		TempFileName:      "temp.json",
+		CallbacksFileName: "callbacks.json",
+		MonitorsFileName:  "monitors.json",
+		IndexFileName:     "data.idx",
+		Fsync:             "always",
+	}
+}
+
+func newRecord(id int64) *domain.Record {
+	return &domain.Record{
+		Links: []domain.LinkEntry{{Link: "google.com", Normalized: "google.com", Result: domain.LinkResult{Status: domain.StatusAvailable}}},
+		ID:    id,
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, cfg *Config)
+	}{
+		{
+			name:    "index is reused",
+			prepare: func(t *testing.T, cfg *Config) {},
+		},
+		{
+			name: "missing index is rebuilt",
+			prepare: func(t *testing.T, cfg *Config) {
+				assert.NoError(t, os.Remove(filepath.Join(cfg.DirPath, cfg.IndexFileName)))
+			},
+		},
+		{
+			name: "torn index is rebuilt",
+			prepare: func(t *testing.T, cfg *Config) {
+				path := filepath.Join(cfg.DirPath, cfg.IndexFileName)
+				data, err := os.ReadFile(path)
+				assert.NoError(t, err)
+				assert.NoError(t, os.WriteFile(path, data[:len(data)-5], 0644))
+			},
+		},
+		{
+			name: "records appended behind the index are indexed",
+			prepare: func(t *testing.T, cfg *Config) {
+				file, err := os.OpenFile(filepath.Join(cfg.DirPath, cfg.FileName), os.O_WRONLY|os.O_APPEND, 0644)
+				assert.NoError(t, err)
+				defer file.Close()
+
+				_, err = file.WriteString(`{"links":{"yandex.ru":"available"},"links_num":4}` + "\n")
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "rewritten data file is reindexed",
+			prepare: func(t *testing.T, cfg *Config) {
+				path := filepath.Join(cfg.DirPath, cfg.FileName)
+				data := `{"links":{},"links_num":9}` + "\n" +
+					`{"links":{"google.com":"available"},"links_num":1}` + "\n" +
+					`{"links":{"google.com":"available"},"links_num":2}` + "\n" +
+					`{"links":{"google.com":"available"},"links_num":3}` + "\n"
+				assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+
+			storage, err := New(cfg, zap.NewNop())
+			assert.NoError(t, err)
+
+			for id := int64(1); id <= 3; id++ {
+				assert.NoError(t, storage.SaveRecord(newRecord(id)))
+			}
+
+			tt.prepare(t, cfg)
+
+			storage, err = New(cfg, zap.NewNop())
+			assert.NoError(t, err)
+
+			records, err := storage.GetRecords([]int64{1, 2, 3, 5})
+			assert.NoError(t, err)
+			assert.Len(t, records, 3)
+
+			for id := int64(1); id <= 3; id++ {
+				assert.Equal(t, newRecord(id), records[id])
+			}
+
+			assert.NoError(t, storage.SaveRecord(newRecord(5)))
+
+			rec, err := storage.GetRecord(5)
+			assert.NoError(t, err)
+			assert.Equal(t, newRecord(5), rec)
+
+			_, err = storage.GetRecord(6)
+			assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       func(t *testing.T) string
+		wantLastID int64
+		wantIDs    []int64
+	}{
+		{
+			name: "torn tail line is truncated",
+			data: func(t *testing.T) string {
+				return encodeTestLines(t, 1, 2, 3) + `{"links":{"google.com":"avail`
+			},
+			wantLastID: 3,
+			wantIDs:    []int64{1, 2, 3},
+		},
+		{
+			name: "last line with bad checksum is truncated",
+			data: func(t *testing.T) string {
+				lines := encodeTestLines(t, 1, 2, 3)
+				return strings.Replace(lines, `"links_num":3`, `"links_num":4`, 1)
+			},
+			wantLastID: 2,
+			wantIDs:    []int64{1, 2},
+		},
+		{
+			name: "legacy lines without checksum",
+			data: func(t *testing.T) string {
+				return `{"links":{"google.com":"available"},"links_num":1}` + "\n" +
+					`{"links":{"google.com":"available"},"links_num":2}` + "\n"
+			},
+			wantLastID: 2,
+			wantIDs:    []int64{1, 2},
+		},
+		{
+			name: "next id is derived from the highest record",
+			data: func(t *testing.T) string {
+				return encodeTestLines(t, 1, 5, 2)
+			},
+			wantLastID: 5,
+			wantIDs:    []int64{1, 2, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			path := filepath.Join(cfg.DirPath, cfg.FileName)
+			assert.NoError(t, os.WriteFile(path, []byte(tt.data(t)), 0644))
+
+			storage, err := New(cfg, zap.NewNop())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantLastID, storage.LoadLastLinksNum())
+
+			records, err := storage.GetRecords(tt.wantIDs)
+			assert.NoError(t, err)
+			assert.Len(t, records, len(tt.wantIDs))
+			for _, rec := range records {
+				assert.Equal(t, newRecord(rec.ID).Links, rec.Links, "links must be read in both the legacy and the ordered form")
+			}
+
+			assert.NoError(t, storage.SaveRecord(newRecord(tt.wantLastID+1)))
+
+			rec, err := storage.GetRecord(tt.wantLastID + 1)
+			assert.NoError(t, err)
+			assert.Equal(t, newRecord(tt.wantLastID+1), rec)
+		})
+	}
+}
+
+func TestUnreadableRecord(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	storage, err := New(cfg, zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 3; id++ {
+		assert.NoError(t, storage.SaveRecord(newRecord(id)))
+	}
+
+	// Record 2 rots on disk after being indexed.
+	path := filepath.Join(cfg.DirPath, cfg.FileName)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[1] = strings.Replace(lines[1], "google.com", "google.org", 1)
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "")), 0644))
+
+	records, err := storage.GetRecords([]int64{1, 2, 3})
+	assert.NoError(t, err, "an unreadable record must not fail the others")
+	assert.Equal(t, map[int64]*domain.Record{1: newRecord(1), 3: newRecord(3)}, records)
+
+	_, err = storage.GetRecord(2)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func encodeTestLines(t *testing.T, ids ...int64) string {
+	t.Helper()
+
+	var lines strings.Builder
+	for _, id := range ids {
+		line, err := encodeLine(newRecord(id))
+		assert.NoError(t, err)
+		lines.Write(line)
+	}
+
+	return lines.String()
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		storage, err := New(newTestConfig(t), zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return storage
+	})
+}
@@ -0,0 +1,281 @@
+package memory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// Storage keeps everything in memory. It is meant for tests and ephemeral
+// deployments, nothing survives a restart.
+type Storage struct {
+	mu          *sync.Mutex
+	records     map[int64]domain.Record
+	tempRecords []domain.Record
+	callbacks   []domain.Callback
+	monitors    map[int64]domain.Monitor
+	lastID      int64
+	// lastMonitorID outlives the deletion of the monitor.
+	lastMonitorID int64
+	logger        *zap.Logger
+}
+
+func New(logger *zap.Logger) *Storage {
+	return &Storage{
+		mu:       &sync.Mutex{},
+		records:  make(map[int64]domain.Record),
+		monitors: make(map[int64]domain.Monitor),
+		logger:   logger,
+	}
+}
+
+func (s *Storage) SaveRecord(record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The first record saved under an ID wins, as in the other repositories.
+	if _, ok := s.records[record.ID]; !ok {
+		s.records[record.ID] = cloneRecord(*record)
+	}
+
+	s.lastID = max(s.lastID, record.ID)
+
+	s.logger.Info("successfully wrote record")
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.tempRecords, func(rec domain.Record) bool {
+		return rec.ID == record.ID
+	})
+	if i >= 0 {
+		s.tempRecords[i] = cloneRecord(*record)
+	} else {
+		s.tempRecords = append(s.tempRecords, cloneRecord(*record))
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+func (s *Storage) LoadTempRecords() ([]domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]domain.Record, 0, len(s.tempRecords))
+	for _, rec := range s.tempRecords {
+		records = append(records, cloneRecord(rec))
+	}
+
+	return records, nil
+}
+
+func (s *Storage) DeleteTempRecord(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tempRecords = slices.DeleteFunc(s.tempRecords, func(rec domain.Record) bool {
+		return rec.ID == id
+	})
+
+	return nil
+}
+
+func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	rec = cloneRecord(rec)
+	return &rec, nil
+}
+
+// GetRecords looks up records by their IDs. IDs without a record are missing
+// from the result.
+func (s *Storage) GetRecords(ids []int64) (map[int64]*domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make(map[int64]*domain.Record, len(ids))
+	for _, id := range ids {
+		rec, ok := s.records[id]
+		if !ok {
+			continue
+		}
+
+		rec = cloneRecord(rec)
+		records[id] = &rec
+	}
+
+	return records, nil
+}
+
+func (s *Storage) FindRecords(filter repository.RecordFilter) ([]domain.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := []domain.Record{}
+	for _, id := range slices.Sorted(maps.Keys(s.records)) {
+		rec := s.records[id]
+		if !filter.Matches(&rec) {
+			continue
+		}
+
+		records = append(records, cloneRecord(rec))
+		if len(records) == filter.Limit {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// LoadLastLinksNum returns the highest links_num among the saved records.
+func (s *Storage) LoadLastLinksNum() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastID
+}
+
+func (s *Storage) SaveCallback(callback *domain.Callback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.callbacks, func(saved domain.Callback) bool {
+		return saved.ID == callback.ID
+	})
+	if i >= 0 {
+		s.callbacks[i] = *callback
+	} else {
+		s.callbacks = append(s.callbacks, *callback)
+	}
+
+	return nil
+}
+
+func (s *Storage) LoadCallbacks() ([]domain.Callback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.callbacks), nil
+}
+
+func (s *Storage) DeleteCallback(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.callbacks = slices.DeleteFunc(s.callbacks, func(callback domain.Callback) bool {
+		return callback.ID == id
+	})
+
+	return nil
+}
+
+func (s *Storage) SaveMonitor(monitor *domain.Monitor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.monitors[monitor.ID] = cloneMonitor(*monitor)
+	s.lastMonitorID = max(s.lastMonitorID, monitor.ID)
+
+	return nil
+}
+
+func (s *Storage) LoadMonitors() ([]domain.Monitor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	monitors := make([]domain.Monitor, 0, len(s.monitors))
+	for _, id := range slices.Sorted(maps.Keys(s.monitors)) {
+		monitors = append(monitors, cloneMonitor(s.monitors[id]))
+	}
+
+	return monitors, nil
+}
+
+func (s *Storage) DeleteMonitor(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.monitors, id)
+
+	return nil
+}
+
+func (s *Storage) LoadLastMonitorID() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastMonitorID, nil
+}
+
+// cloneRecord deep-copies rec, so that callers cannot modify stored records
+// through the slices and pointers they share.
+func cloneRecord(rec domain.Record) domain.Record {
+	rec.Links = slices.Clone(rec.Links)
+	for i := range rec.Links {
+		rec.Links[i].Result = cloneResult(rec.Links[i].Result)
+	}
+
+	if rec.Availability != nil {
+		availability := *rec.Availability
+		availability.Statuses = slices.Clone(availability.Statuses)
+		rec.Availability = &availability
+	}
+
+	if rec.Timing != nil {
+		timing := *rec.Timing
+		rec.Timing = &timing
+	}
+
+	return rec
+}
+
+func cloneResult(result domain.LinkResult) domain.LinkResult {
+	result.Redirects = slices.Clone(result.Redirects)
+	result.Addresses = slices.Clone(result.Addresses)
+
+	if result.TLS != nil {
+		info := *result.TLS
+		info.Chain = slices.Clone(info.Chain)
+		for i := range info.Chain {
+			info.Chain[i].SANs = slices.Clone(info.Chain[i].SANs)
+		}
+		result.TLS = &info
+	}
+
+	if result.Timing != nil {
+		timing := *result.Timing
+		result.Timing = &timing
+	}
+
+	return result
+}
+
+// cloneMonitor copies monitor along with its links, runs and availability.
+func cloneMonitor(monitor domain.Monitor) domain.Monitor {
+	monitor.Links = slices.Clone(monitor.Links)
+	monitor.Runs = slices.Clone(monitor.Runs)
+
+	if monitor.Availability != nil {
+		availability := *monitor.Availability
+		availability.Statuses = slices.Clone(availability.Statuses)
+		monitor.Availability = &availability
+	}
+
+	return monitor
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return New(zap.NewNop())
+	})
+}
+
+func TestRecordsAreCopied(t *testing.T) {
+	newRecord := func() *domain.Record {
+		return &domain.Record{
+			ID: 1,
+			Links: []domain.LinkEntry{{
+				Link:       "https://google.com",
+				Normalized: "https://google.com/",
+				Result: domain.LinkResult{
+					Status:    domain.StatusRedirected,
+					Redirects: []domain.Redirect{{URL: "https://google.com/", StatusCode: 301, Location: "https://www.google.com/"}},
+					TLS: &domain.TLSInfo{
+						Chain: []domain.Certificate{{Subject: "google.com", SANs: []string{"google.com"}, NotAfter: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}},
+					},
+					Timing:    &domain.Timing{TotalMs: 10},
+					Addresses: []string{"192.0.2.1"},
+				},
+			}},
+			Availability: &domain.Availability{Statuses: []domain.StatusRange{{From: 200, To: 299}}},
+			Timing:       &domain.TimingStats{Links: 1},
+		}
+	}
+
+	mutate := func(rec *domain.Record) {
+		result := &rec.Links[0].Result
+		result.Redirects[0].Location = "https://evil.test/"
+		result.TLS.Chain[0].SANs[0] = "evil.test"
+		result.TLS.HostnameMismatch = true
+		result.Timing.TotalMs = 0
+		result.Addresses[0] = "10.0.0.1"
+		rec.Availability.Statuses[0].To = 599
+		rec.Timing.Links = 0
+	}
+
+	storage := New(zap.NewNop())
+
+	saved := newRecord()
+	assert.NoError(t, storage.SaveRecord(saved))
+	mutate(saved)
+
+	got, err := storage.GetRecord(1)
+	assert.NoError(t, err)
+	assert.Equal(t, newRecord(), got, "changing a saved record must not change the stored one")
+
+	mutate(got)
+
+	got, err = storage.GetRecord(1)
+	assert.NoError(t, err)
+	assert.Equal(t, newRecord(), got, "changing a returned record must not change the stored one")
+}
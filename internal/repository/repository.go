@@ -1,12 +1,87 @@
 package repository
 
-import "link-service/internal/domain"
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+)
 
 type Repository interface {
 	SaveRecord(record *domain.Record) error
+	// SaveTempRecord creates the temp record or replaces the one with the
+	// same ID. The old record is kept until the new one is written.
 	SaveTempRecord(record *domain.Record) error
 	LoadTempRecords() ([]domain.Record, error)
+	DeleteTempRecord(id int64) error
 	GetRecord(id int64) (*domain.Record, error)
-	ClearTempFile() error
+	// GetRecords returns the records found among ids. A record that is
+	// missing or cannot be read leaves a gap instead of failing the others.
+	GetRecords(ids []int64) (map[int64]*domain.Record, error)
+	// FindRecords returns the records matching filter, ordered by links_num.
+	FindRecords(filter RecordFilter) ([]domain.Record, error)
 	LoadLastLinksNum() int64
+	SaveCallback(callback *domain.Callback) error
+	LoadCallbacks() ([]domain.Callback, error)
+	DeleteCallback(id int64) error
+	// SaveMonitor creates the monitor or replaces the one with the same ID.
+	SaveMonitor(monitor *domain.Monitor) error
+	LoadMonitors() ([]domain.Monitor, error)
+	DeleteMonitor(id int64) error
+	// LoadLastMonitorID returns the highest monitor ID ever saved, deleted
+	// monitors included, so that their IDs are not reused.
+	LoadLastMonitorID() (int64, error)
+}
+
+// RecordFilter selects the records returned by FindRecords. Zero fields match
+// every record.
+type RecordFilter struct {
+	// Status matches the records with at least one link of that status.
+	Status    string
+	MonitorID int64
+	// CheckedFrom and CheckedTo bound the time of the latest check of a
+	// record, see domain.Record.CheckedAt. CheckedTo is exclusive.
+	CheckedFrom time.Time
+	CheckedTo   time.Time
+	// Limit is the maximum number of records returned, 0 means no limit.
+	Limit int
+}
+
+// Matches reports whether rec is selected by f, regardless of f.Limit.
+func (f RecordFilter) Matches(rec *domain.Record) bool {
+	if f.MonitorID != 0 && rec.MonitorID != f.MonitorID {
+		return false
+	}
+
+	if f.Status != "" && !slices.ContainsFunc(rec.Links, func(entry domain.LinkEntry) bool {
+		return entry.Result.Status == f.Status
+	}) {
+		return false
+	}
+
+	if f.CheckedFrom.IsZero() && f.CheckedTo.IsZero() {
+		return true
+	}
+
+	checkedAt := rec.CheckedAt()
+	if checkedAt.IsZero() {
+		return false
+	}
+
+	if !f.CheckedFrom.IsZero() && checkedAt.Before(f.CheckedFrom) {
+		return false
+	}
+
+	return f.CheckedTo.IsZero() || checkedAt.Before(f.CheckedTo)
+}
+
+// IDAllocator is implemented by repositories that allocate links_num
+// themselves. The service then uses it instead of its in-memory counter.
+type IDAllocator interface {
+	NextLinksNum() (int64, error)
 }
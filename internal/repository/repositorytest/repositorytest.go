@@ -0,0 +1,335 @@
+// Package repositorytest provides a conformance suite shared by the
+// repository.Repository implementations.
+package repositorytest
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// Run runs the conformance suite against the repositories returned by
+// newRepo. Every call of newRepo must return a new, empty repository.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.Repository) {
+	t.Run("records", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetRecord(1)
+		assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+		assert.Equal(t, int64(0), repo.LoadLastLinksNum())
+
+		assert.NoError(t, repo.SaveRecord(newRecord(2, "google.com")))
+		assert.NoError(t, repo.SaveRecord(newRecord(1, "ya.ru")))
+
+		rec, err := repo.GetRecord(2)
+		assert.NoError(t, err)
+		assert.Equal(t, newRecord(2, "google.com"), rec)
+
+		_, err = repo.GetRecord(3)
+		assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+		assert.NoError(t, repo.SaveRecord(newRecord(2, "go.dev")), "saving a record under a taken ID must not fail")
+
+		rec, err = repo.GetRecord(2)
+		assert.NoError(t, err)
+		assert.Equal(t, newRecord(2, "google.com"), rec, "the first record saved under an ID must win")
+
+		records, err := repo.GetRecords([]int64{1, 2, 3})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int64]*domain.Record{
+			1: newRecord(1, "ya.ru"),
+			2: newRecord(2, "google.com"),
+		}, records)
+
+		assert.Equal(t, int64(2), repo.LoadLastLinksNum(), "last links_num must be the highest one")
+	})
+
+	t.Run("records are copied", func(t *testing.T) {
+		repo := newRepo(t)
+
+		rec := newRecord(1, "google.com")
+		assert.NoError(t, repo.SaveRecord(rec))
+		rec.Links[0].Result.Status = domain.StatusNotAvailable
+
+		got, err := repo.GetRecord(1)
+		assert.NoError(t, err)
+		assert.Equal(t, newRecord(1, "google.com"), got)
+
+		got.Links[0].Result.Status = domain.StatusNotAvailable
+
+		got, err = repo.GetRecord(1)
+		assert.NoError(t, err)
+		assert.Equal(t, newRecord(1, "google.com"), got)
+	})
+
+	t.Run("find records", func(t *testing.T) {
+		repo := newRepo(t)
+
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		records := []*domain.Record{
+			newRecord(1, "google.com"),
+			newRecord(2, "ya.ru"),
+			newRecord(3, "go.dev"),
+			newRecord(4, "example.com"),
+		}
+		records[0].Links[0].Result.CheckedAt = start
+		records[1].Links[0].Result.CheckedAt = start.Add(time.Hour)
+		records[1].Links = append(records[1].Links, domain.LinkEntry{
+			Index:      1,
+			Link:       "down.test",
+			Normalized: "down.test",
+			Result:     domain.LinkResult{Status: domain.StatusNotAvailable, CheckedAt: start.Add(2 * time.Hour)},
+		})
+		records[1].MonitorID = 7
+		records[2].Links[0].Result.CheckedAt = start.Add(3 * time.Hour)
+		records[2].MonitorID = 7
+
+		for _, rec := range slices.Backward(records) {
+			assert.NoError(t, repo.SaveRecord(rec))
+		}
+
+		tests := []struct {
+			name    string
+			filter  repository.RecordFilter
+			wantIDs []int64
+		}{
+			{name: "all", filter: repository.RecordFilter{}, wantIDs: []int64{1, 2, 3, 4}},
+			{name: "limit", filter: repository.RecordFilter{Limit: 2}, wantIDs: []int64{1, 2}},
+			{name: "status", filter: repository.RecordFilter{Status: domain.StatusNotAvailable}, wantIDs: []int64{2}},
+			{name: "monitor", filter: repository.RecordFilter{MonitorID: 7}, wantIDs: []int64{2, 3}},
+			{name: "checked from", filter: repository.RecordFilter{CheckedFrom: start.Add(2 * time.Hour)}, wantIDs: []int64{2, 3}},
+			{name: "checked to", filter: repository.RecordFilter{CheckedTo: start.Add(2 * time.Hour)}, wantIDs: []int64{1}},
+			{
+				name: "combined",
+				filter: repository.RecordFilter{
+					Status:      domain.StatusAvailable,
+					MonitorID:   7,
+					CheckedFrom: start.Add(time.Hour),
+					CheckedTo:   start.Add(4 * time.Hour),
+					Limit:       1,
+				},
+				wantIDs: []int64{2},
+			},
+			{name: "none", filter: repository.RecordFilter{Status: domain.StatusBlocked}, wantIDs: []int64{}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := repo.FindRecords(tt.filter)
+				assert.NoError(t, err)
+
+				ids := []int64{}
+				for _, rec := range found {
+					ids = append(ids, rec.ID)
+				}
+				assert.Equal(t, tt.wantIDs, ids)
+			})
+		}
+
+		found, err := repo.FindRecords(repository.RecordFilter{MonitorID: 7, Limit: 1})
+		assert.NoError(t, err)
+		if assert.Len(t, found, 1) {
+			assert.Equal(t, *records[1], found[0])
+		}
+	})
+
+	t.Run("temp records", func(t *testing.T) {
+		repo := newRepo(t)
+
+		records, err := repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+
+		assert.NoError(t, repo.SaveTempRecord(newRecord(1, "google.com")))
+		assert.NoError(t, repo.SaveTempRecord(newRecord(2, "ya.ru")))
+		assert.NoError(t, repo.SaveTempRecord(newRecord(3, "go.dev")))
+
+		records, err = repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Record{
+			*newRecord(1, "google.com"),
+			*newRecord(2, "ya.ru"),
+			*newRecord(3, "go.dev"),
+		}, records)
+
+		assert.NoError(t, repo.DeleteTempRecord(2))
+		assert.NoError(t, repo.DeleteTempRecord(4), "deleting a missing temp record must not fail")
+
+		records, err = repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Record{
+			*newRecord(1, "google.com"),
+			*newRecord(3, "go.dev"),
+		}, records)
+
+		assert.NoError(t, repo.SaveTempRecord(newRecord(3, "example.com")))
+
+		records, err = repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Record{
+			*newRecord(1, "google.com"),
+			*newRecord(3, "example.com"),
+		}, records, "saving a temp record again must replace it")
+
+		assert.NoError(t, repo.DeleteTempRecord(3))
+
+		records, err = repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Record{*newRecord(1, "google.com")}, records, "deleting a replaced temp record must remove all of its versions")
+
+		_, err = repo.GetRecord(1)
+		assert.ErrorIs(t, err, repository.ErrRecordNotFound, "temp records must not be visible as records")
+	})
+
+	t.Run("callbacks", func(t *testing.T) {
+		repo := newRepo(t)
+
+		callbacks, err := repo.LoadCallbacks()
+		assert.NoError(t, err)
+		assert.Empty(t, callbacks)
+
+		assert.NoError(t, repo.SaveCallback(&domain.Callback{ID: 1, URL: "http://example.com/1"}))
+		assert.NoError(t, repo.SaveCallback(&domain.Callback{ID: 2, URL: "http://example.com/2"}))
+
+		callbacks, err = repo.LoadCallbacks()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Callback{
+			{ID: 1, URL: "http://example.com/1"},
+			{ID: 2, URL: "http://example.com/2"},
+		}, callbacks)
+
+		assert.NoError(t, repo.SaveCallback(&domain.Callback{ID: 1, URL: "http://example.com/3"}))
+
+		callbacks, err = repo.LoadCallbacks()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Callback{
+			{ID: 1, URL: "http://example.com/3"},
+			{ID: 2, URL: "http://example.com/2"},
+		}, callbacks, "a callback saved twice must be replaced")
+
+		assert.NoError(t, repo.DeleteCallback(1))
+
+		callbacks, err = repo.LoadCallbacks()
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Callback{{ID: 2, URL: "http://example.com/2"}}, callbacks)
+	})
+
+	t.Run("monitors", func(t *testing.T) {
+		repo := newRepo(t)
+
+		monitors, err := repo.LoadMonitors()
+		assert.NoError(t, err)
+		assert.Empty(t, monitors)
+
+		lastID, err := repo.LoadLastMonitorID()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), lastID)
+
+		assert.NoError(t, repo.SaveMonitor(newMonitor(2, "docs")))
+		assert.NoError(t, repo.SaveMonitor(newMonitor(1, "site")))
+
+		updated := newMonitor(1, "site")
+		updated.Runs = []domain.MonitorRun{{ID: 7, Total: 1, Available: 1}}
+		updated.Uptime = 1
+		assert.NoError(t, repo.SaveMonitor(updated), "saving a monitor again must replace it")
+
+		monitors, err = repo.LoadMonitors()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []domain.Monitor{*updated, *newMonitor(2, "docs")}, monitors)
+
+		assert.NoError(t, repo.DeleteMonitor(2))
+		assert.NoError(t, repo.DeleteMonitor(3), "deleting a missing monitor must not fail")
+
+		monitors, err = repo.LoadMonitors()
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Monitor{*updated}, monitors)
+
+		lastID, err = repo.LoadLastMonitorID()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), lastID, "the ID of a deleted monitor must be remembered")
+
+		assert.NoError(t, repo.DeleteMonitor(1))
+		assert.NoError(t, repo.SaveMonitor(newMonitor(3, "blog")))
+		assert.NoError(t, repo.DeleteMonitor(3))
+
+		monitors, err = repo.LoadMonitors()
+		assert.NoError(t, err)
+		assert.Empty(t, monitors)
+
+		lastID, err = repo.LoadLastMonitorID()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), lastID, "the ID of a deleted monitor must be remembered")
+	})
+
+	t.Run("concurrent writes", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const n = 50
+
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			errs []error
+		)
+		for i := range int64(n) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				err := errors.Join(
+					repo.SaveRecord(newRecord(i+1, "google.com")),
+					repo.SaveTempRecord(newRecord(i+1, "ya.ru")),
+				)
+
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		assert.NoError(t, errors.Join(errs...))
+
+		ids := make([]int64, n)
+		for i := range ids {
+			ids[i] = int64(i + 1)
+		}
+
+		records, err := repo.GetRecords(ids)
+		assert.NoError(t, err)
+		assert.Len(t, records, n)
+
+		tempRecords, err := repo.LoadTempRecords()
+		assert.NoError(t, err)
+		assert.Len(t, tempRecords, n)
+
+		assert.Equal(t, int64(n), repo.LoadLastLinksNum())
+	})
+}
+
+func newRecord(id int64, link string) *domain.Record {
+	return &domain.Record{
+		Links: []domain.LinkEntry{{Index: 0, Link: link, Normalized: link, Result: domain.LinkResult{Status: domain.StatusAvailable, StatusCode: 200}}},
+		ID:    id,
+	}
+}
+
+func newMonitor(id int64, name string) *domain.Monitor {
+	return &domain.Monitor{
+		ID:        id,
+		Name:      name,
+		Links:     []string{"google.com"},
+		Schedule:  "@every 5m",
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NextRunAt: time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC),
+		Runs:      []domain.MonitorRun{},
+	}
+}
@@ -0,0 +1,544 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// maxBatchSize bounds the number of IDs bound into a single query, SQLite
+// limits the number of host parameters per statement.
+const maxBatchSize = 500
+
+const schema = `
+CREATE TABLE IF NOT EXISTS records (
+	id         INTEGER PRIMARY KEY,
+	data       TEXT NOT NULL,
+	monitor_id INTEGER NOT NULL DEFAULT 0,
+	-- checked_at is the time of the latest check in unix nanoseconds, NULL
+	-- if no link was checked.
+	checked_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS records_monitor_id ON records (monitor_id, id);
+CREATE INDEX IF NOT EXISTS records_checked_at ON records (checked_at);
+
+-- record_links holds the statuses of the links of records, for filtering.
+CREATE TABLE IF NOT EXISTS record_links (
+	record_id INTEGER NOT NULL,
+	idx       INTEGER NOT NULL,
+	status    TEXT NOT NULL,
+	PRIMARY KEY (record_id, idx)
+);
+
+CREATE INDEX IF NOT EXISTS record_links_status ON record_links (status, record_id);
+
+CREATE TABLE IF NOT EXISTS temp_records (
+	id   INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS callbacks (
+	id  INTEGER PRIMARY KEY,
+	url TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS monitors (
+	id   INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS links_num (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	last_id INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO links_num (id, last_id) VALUES (1, 0);
+
+CREATE TABLE IF NOT EXISTS monitor_id (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	last_id INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO monitor_id (id, last_id) VALUES (1, 0);
+`
+
+type Config struct {
+	DirPath  string `env:"STORAGE_DIR_PATH" env-required:"true"`
+	FileName string `env:"STORAGE_SQLITE_FILE_NAME" env-default:"links.db"`
+}
+
+type Storage struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func New(cfg *Config, logger *zap.Logger) (*Storage, error) {
+	err := os.MkdirAll(cfg.DirPath, 0755)
+	if err != nil {
+		logger.Error("failed to create dir", zap.String("dir_path", cfg.DirPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to create dir: %s: %w", cfg.DirPath, err)
+	}
+
+	path := filepath.Join(cfg.DirPath, cfg.FileName)
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		logger.Error("failed to open database", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open database: %s: %w", path, err)
+	}
+
+	// SQLite serializes writers anyway, a single connection avoids
+	// SQLITE_BUSY between the pool's own connections.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		_ = db.Close()
+		logger.Error("failed to create schema", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to create schema: %s: %w", path, err)
+	}
+
+	logger.Info("database opened", zap.String("path", path))
+
+	return &Storage{
+		db:     db,
+		logger: logger,
+	}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) SaveRecord(record *domain.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Error(err))
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	var checkedAt sql.NullInt64
+	if t := record.CheckedAt(); !t.IsZero() {
+		checkedAt = sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The first record saved under an ID wins, as in the other repositories.
+	res, err := tx.Exec(`INSERT OR IGNORE INTO records (id, data, monitor_id, checked_at) VALUES (?, ?, ?, ?)`,
+		record.ID, data, record.MonitorID, checkedAt)
+	if err != nil {
+		s.logger.Error("failed to write record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	if inserted > 0 {
+		for _, entry := range record.Links {
+			_, err = tx.Exec(`INSERT INTO record_links (record_id, idx, status) VALUES (?, ?, ?)`,
+				record.ID, entry.Index, entry.Result.Status)
+			if err != nil {
+				s.logger.Error("failed to write record links", zap.Int64("id", record.ID), zap.Error(err))
+				return fmt.Errorf("failed to write record links: %w", err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.logger.Error("failed to commit record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to commit record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote record")
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(record *domain.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal temp record", zap.Error(err))
+		return fmt.Errorf("failed to marshal temp record: %w", err)
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO temp_records (id, data) VALUES (?, ?)`, record.ID, data)
+	if err != nil {
+		s.logger.Error("failed to write temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to write temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+func (s *Storage) LoadTempRecords() ([]domain.Record, error) {
+	rows, err := s.db.Query(`SELECT data FROM temp_records ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query temp records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []domain.Record
+	for rows.Next() {
+		var data []byte
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan temp record: %w", err)
+		}
+
+		var rec domain.Record
+		err = json.Unmarshal(data, &rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+		}
+
+		records = append(records, rec)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query temp records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) DeleteTempRecord(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM temp_records WHERE id = ?`, id)
+	if err != nil {
+		s.logger.Error("failed to delete temp record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete temp record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM records WHERE id = ?`, id).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+		}
+
+		s.logger.Error("failed to get record", zap.Int64("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get record: %d: %w", id, err)
+	}
+
+	var rec domain.Record
+	err = json.Unmarshal(data, &rec)
+	if err != nil {
+		s.logger.Error("failed to unmarshal record", zap.Int64("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to unmarshal record: %d: %w", id, err)
+	}
+
+	return &rec, nil
+}
+
+// GetRecords looks up records by their IDs. IDs without a record, or whose
+// record cannot be decoded, are missing from the result.
+func (s *Storage) GetRecords(ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+
+	for start := 0; start < len(ids); start += maxBatchSize {
+		batch := ids[start:min(start+maxBatchSize, len(ids))]
+
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+
+		err := s.queryRecords(`SELECT id, data FROM records WHERE id IN (`+placeholders+`)`, args, records)
+		if err != nil {
+			s.logger.Error("failed to get records", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Storage) FindRecords(filter repository.RecordFilter) ([]domain.Record, error) {
+	var (
+		conditions = []string{"1 = 1"}
+		args       []any
+	)
+
+	if filter.MonitorID != 0 {
+		conditions = append(conditions, "monitor_id = ?")
+		args = append(args, filter.MonitorID)
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "id IN (SELECT record_id FROM record_links WHERE status = ?)")
+		args = append(args, filter.Status)
+	}
+
+	if !filter.CheckedFrom.IsZero() {
+		conditions = append(conditions, "checked_at >= ?")
+		args = append(args, filter.CheckedFrom.UnixNano())
+	}
+
+	if !filter.CheckedTo.IsZero() {
+		conditions = append(conditions, "checked_at < ?")
+		args = append(args, filter.CheckedTo.UnixNano())
+	}
+
+	query := `SELECT id, data FROM records WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	found := make(map[int64]*domain.Record)
+	err := s.queryRecords(query, args, found)
+	if err != nil {
+		s.logger.Error("failed to find records", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]domain.Record, 0, len(found))
+	for _, id := range slices.Sorted(maps.Keys(found)) {
+		records = append(records, *found[id])
+	}
+
+	return records, nil
+}
+
+func (s *Storage) queryRecords(query string, args []any, records map[int64]*domain.Record) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int64
+			data []byte
+		)
+
+		err = rows.Scan(&id, &data)
+		if err != nil {
+			return fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		var rec domain.Record
+		err = json.Unmarshal(data, &rec)
+		if err != nil {
+			s.logger.Error("skipping unreadable record", zap.Int64("id", id), zap.Error(err))
+			continue
+		}
+
+		records[id] = &rec
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest links_num among the saved records.
+func (s *Storage) LoadLastLinksNum() int64 {
+	var id int64
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM records`).Scan(&id)
+	if err != nil {
+		s.logger.Error("failed to load last links num", zap.Error(err))
+		return 0
+	}
+
+	return id
+}
+
+// NextLinksNum allocates a new links_num in a transaction. Allocated IDs are
+// never handed out again, even if no record is saved under them, and are
+// always above the IDs of saved and temp records.
+func (s *Storage) NextLinksNum() (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`
+		UPDATE links_num SET last_id = MAX(
+			last_id,
+			(SELECT COALESCE(MAX(id), 0) FROM records),
+			(SELECT COALESCE(MAX(id), 0) FROM temp_records)
+		) + 1
+		WHERE id = 1
+		RETURNING last_id`).Scan(&id)
+	if err != nil {
+		s.logger.Error("failed to allocate links num", zap.Error(err))
+		return 0, fmt.Errorf("failed to allocate links num: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.logger.Error("failed to commit links num", zap.Error(err))
+		return 0, fmt.Errorf("failed to commit links num: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) SaveCallback(callback *domain.Callback) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO callbacks (id, url) VALUES (?, ?)`, callback.ID, callback.URL)
+	if err != nil {
+		s.logger.Error("failed to write callback", zap.Int64("id", callback.ID), zap.Error(err))
+		return fmt.Errorf("failed to write callback: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) LoadCallbacks() ([]domain.Callback, error) {
+	rows, err := s.db.Query(`SELECT id, url FROM callbacks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var callbacks []domain.Callback
+	for rows.Next() {
+		var callback domain.Callback
+		err = rows.Scan(&callback.ID, &callback.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan callback: %w", err)
+		}
+
+		callbacks = append(callbacks, callback)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query callbacks: %w", err)
+	}
+
+	return callbacks, nil
+}
+
+func (s *Storage) DeleteCallback(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM callbacks WHERE id = ?`, id)
+	if err != nil {
+		s.logger.Error("failed to delete callback", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete callback: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveMonitor(monitor *domain.Monitor) error {
+	data, err := json.Marshal(monitor)
+	if err != nil {
+		s.logger.Error("failed to marshal monitor", zap.Error(err))
+		return fmt.Errorf("failed to marshal monitor: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO monitors (id, data) VALUES (?, ?)`, monitor.ID, data)
+	if err != nil {
+		s.logger.Error("failed to write monitor", zap.Int64("id", monitor.ID), zap.Error(err))
+		return fmt.Errorf("failed to write monitor: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE monitor_id SET last_id = MAX(last_id, ?) WHERE id = 1`, monitor.ID)
+	if err != nil {
+		s.logger.Error("failed to write last monitor id", zap.Int64("id", monitor.ID), zap.Error(err))
+		return fmt.Errorf("failed to write last monitor id: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.logger.Error("failed to commit monitor", zap.Int64("id", monitor.ID), zap.Error(err))
+		return fmt.Errorf("failed to commit monitor: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) LoadMonitors() ([]domain.Monitor, error) {
+	rows, err := s.db.Query(`SELECT data FROM monitors ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monitors: %w", err)
+	}
+	defer rows.Close()
+
+	var monitors []domain.Monitor
+	for rows.Next() {
+		var data []byte
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan monitor: %w", err)
+		}
+
+		var monitor domain.Monitor
+		err = json.Unmarshal(data, &monitor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode monitor: %w", err)
+		}
+
+		monitors = append(monitors, monitor)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monitors: %w", err)
+	}
+
+	return monitors, nil
+}
+
+func (s *Storage) LoadLastMonitorID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT last_id FROM monitor_id WHERE id = 1`).Scan(&id)
+	if err != nil {
+		s.logger.Error("failed to load last monitor id", zap.Error(err))
+		return 0, fmt.Errorf("failed to load last monitor id: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) DeleteMonitor(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM monitors WHERE id = ?`, id)
+	if err != nil {
+		s.logger.Error("failed to delete monitor", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete monitor: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+func TestNextLinksNum(t *testing.T) {
+	cfg := &Config{
+		DirPath:  t.TempDir(),
+		FileName: "links.db",
+	}
+
+	storage, err := New(cfg, zap.NewNop())
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.SaveRecord(&domain.Record{ID: 3}))
+	assert.NoError(t, storage.SaveTempRecord(&domain.Record{ID: 5}))
+
+	id, err := storage.NextLinksNum()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), id, "allocated ids must be above temp records")
+
+	id, err = storage.NextLinksNum()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), id)
+
+	assert.NoError(t, storage.Close())
+
+	storage, err = New(cfg, zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	id, err = storage.NextLinksNum()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), id, "allocated ids must not be reused after restart")
+
+	assert.Equal(t, int64(3), storage.LoadLastLinksNum())
+}
+
+func TestUnreadableRecord(t *testing.T) {
+	storage, err := New(&Config{DirPath: t.TempDir(), FileName: "links.db"}, zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	assert.NoError(t, storage.SaveRecord(&domain.Record{ID: 1}))
+	assert.NoError(t, storage.SaveRecord(&domain.Record{ID: 3}))
+
+	_, err = storage.db.Exec(`INSERT INTO records (id, data) VALUES (2, 'not json')`)
+	assert.NoError(t, err)
+
+	records, err := storage.GetRecords([]int64{1, 2, 3})
+	assert.NoError(t, err, "an unreadable record must not fail the others")
+	assert.Equal(t, map[int64]*domain.Record{1: {ID: 1}, 3: {ID: 3}}, records)
+
+	_, err = storage.GetRecord(2)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		storage, err := New(&Config{DirPath: t.TempDir(), FileName: "links.db"}, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+
+		return storage
+	})
+}
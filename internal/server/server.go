@@ -36,6 +36,13 @@ func New(ctx context.Context, srv *service.Service, cfgLogger *logger.Config, cf
 
 	router.Post("/links", handler.ProcessLinks(ctx, srv, cfgServer.Timeout, log))
 	router.Get("/links", handler.GetLinks(repo, log))
+	router.Get("/records", handler.FindRecords(repo, log))
+	router.Get("/jobs/{id}", handler.GetJob(srv, log))
+	router.Get("/admin/circuits", handler.GetCircuits(srv, log))
+	router.Post("/monitors", handler.CreateMonitor(srv, log))
+	router.Get("/monitors", handler.GetMonitors(srv, log))
+	router.Get("/monitors/{id}", handler.GetMonitor(srv, log))
+	router.Delete("/monitors/{id}", handler.DeleteMonitor(srv, log))
 
 	return http.Server{
 		Addr:    addr,
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"link-service/internal/domain"
+)
+
+// defaultStatuses are accepted when no status ranges are configured.
+var defaultStatuses = []domain.StatusRange{{From: 200, To: 299}}
+
+// StatusRanges is a list of accepted status codes, set from the environment
+// as comma separated codes and ranges, e.g. "200-299,304".
+type StatusRanges []domain.StatusRange
+
+// SetValue implements cleanenv.Setter.
+func (r *StatusRanges) SetValue(value string) error {
+	ranges, err := ParseStatusRanges(value)
+	if err != nil {
+		return err
+	}
+
+	*r = ranges
+	return nil
+}
+
+// ParseStatusRanges parses comma separated status codes and ranges, e.g.
+// "200-299,304".
+func ParseStatusRanges(value string) ([]domain.StatusRange, error) {
+	var ranges []domain.StatusRange
+
+	for part := range strings.SplitSeq(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+
+		r, err := parseStatusRange(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status range %q: %w", part, err)
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("no status ranges")
+	}
+
+	return ranges, nil
+}
+
+func parseStatusRange(from string, to string) (domain.StatusRange, error) {
+	var (
+		r   domain.StatusRange
+		err error
+	)
+
+	r.From, err = strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return r, err
+	}
+
+	r.To, err = strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return r, err
+	}
+
+	if r.From < 100 || r.To > 599 || r.From > r.To {
+		return r, errors.New("codes must be in 100-599 and ordered")
+	}
+
+	return r, nil
+}
+
+// Availability returns the default availability policy of the service.
+func (s *Service) Availability() domain.Availability {
+	return s.availability
+}
+
+// policy returns the availability policy of a check made with opts.
+func (s *Service) policy(opts Options) domain.Availability {
+	if opts.Availability != nil {
+		return *opts.Availability
+	}
+
+	return s.availability
+}
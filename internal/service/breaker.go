@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+var errCircuitOpen = errors.New("circuit is open")
+
+// circuitBreaker stops checking hosts that keep failing. After threshold
+// consecutive failures the circuit of a host opens and its links are not
+// checked for the cooldown. Then a single probe check is let through: its
+// success closes the circuit, its failure opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	mu        *sync.Mutex
+	hosts     map[string]*circuit
+}
+
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(cfg *Config) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: max(cfg.BreakerFailures, 0),
+		cooldown:  cfg.BreakerCooldown,
+		mu:        &sync.Mutex{},
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+// allow reports whether a check against host may run at now. A check allowed
+// through a half-open circuit is the probe, its outcome must be reported with
+// success, failure or cancel.
+func (b *circuitBreaker) allow(host string, now time.Time) bool {
+	if b.threshold == 0 || host == "" {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		return true
+	}
+
+	switch c.state {
+	case domain.CircuitOpen:
+		if now.Before(c.openedAt.Add(b.cooldown)) {
+			return false
+		}
+
+		c.state = domain.CircuitHalfOpen
+		c.probing = true
+		return true
+
+	case domain.CircuitHalfOpen:
+		if c.probing {
+			return false
+		}
+
+		c.probing = true
+		return true
+
+	default:
+		return true
+	}
+}
+
+// success closes the circuit of host.
+func (b *circuitBreaker) success(host string) {
+	if b.threshold == 0 || host == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.hosts, host)
+}
+
+// failure counts a failed check of host and opens its circuit once the
+// threshold is reached or the probe of a half-open circuit failed.
+func (b *circuitBreaker) failure(host string, now time.Time) {
+	if b.threshold == 0 || host == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{state: domain.CircuitClosed}
+		b.hosts[host] = c
+	}
+
+	c.failures++
+	c.probing = false
+
+	if c.state == domain.CircuitHalfOpen || c.failures >= b.threshold {
+		c.state = domain.CircuitOpen
+		c.openedAt = now
+	}
+}
+
+// cancel gives up the probe of a half-open circuit of host whose check was
+// aborted, so that the next check probes instead.
+func (b *circuitBreaker) cancel(host string) {
+	if b.threshold == 0 || host == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.hosts[host]; ok {
+		c.probing = false
+	}
+}
+
+// retryAt returns the time an open circuit of host lets a probe through.
+func (b *circuitBreaker) retryAt(host string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok {
+		return time.Time{}
+	}
+
+	return c.openedAt.Add(b.cooldown)
+}
+
+// circuits returns the circuits of the failing hosts, sorted by host.
+func (b *circuitBreaker) circuits() []domain.Circuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	circuits := make([]domain.Circuit, 0, len(b.hosts))
+	for host, c := range b.hosts {
+		circuit := domain.Circuit{
+			Host:     host,
+			State:    c.state,
+			Failures: c.failures,
+		}
+
+		if c.state != domain.CircuitClosed {
+			retryAt := c.openedAt.Add(b.cooldown).UTC()
+			circuit.RetryAt = &retryAt
+		}
+
+		circuits = append(circuits, circuit)
+	}
+
+	slices.SortFunc(circuits, func(a, b domain.Circuit) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+
+	return circuits
+}
+
+// hostFailed reports whether result shows that its host is unreachable, as
+// opposed to answering with an error.
+func hostFailed(result domain.LinkResult) bool {
+	if result.Status != domain.StatusNotAvailable {
+		return false
+	}
+
+	switch result.ErrorClass {
+	case domain.ErrorClassDNS, domain.ErrorClassConnect, domain.ErrorClassTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Circuits returns the state of the circuit breakers of the failing hosts.
+func (s *Service) Circuits() []domain.Circuit {
+	return s.breaker.circuits()
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// resultCache keeps the results of recent checks for ttl and coalesces
+// concurrent checks of the same link, so that they share one outbound check.
+type resultCache struct {
+	ttl     time.Duration
+	size    int
+	mu      *sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	result  domain.LinkResult
+	expires time.Time
+}
+
+// cacheCall is a check in flight, its result is set before done is closed.
+type cacheCall struct {
+	done   chan struct{}
+	result domain.LinkResult
+}
+
+func newResultCache(cfg *Config) *resultCache {
+	return &resultCache{
+		ttl:     cfg.CacheTTL,
+		size:    max(cfg.CacheSize, 1),
+		mu:      &sync.Mutex{},
+		entries: make(map[string]cacheEntry),
+		calls:   make(map[string]*cacheCall),
+	}
+}
+
+// lookup returns the cached result for key if there is a fresh one.
+// Otherwise it returns the call checking key, and whether the caller has
+// just started it and must finish it.
+func (c *resultCache) lookup(key string, now time.Time) (domain.LinkResult, *cacheCall, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		if now.Before(entry.expires) {
+			return entry.result, nil, false
+		}
+
+		delete(c.entries, key)
+	}
+
+	if call, ok := c.calls[key]; ok {
+		return domain.LinkResult{}, call, false
+	}
+
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+
+	return domain.LinkResult{}, call, true
+}
+
+// finish completes call with result, caching it if it is the outcome of an
+// actual check.
+func (c *resultCache) finish(key string, call *cacheCall, result domain.LinkResult, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+
+	c.store(key, result, now)
+
+	call.result = result
+	close(call.done)
+}
+
+// put caches result for key. c.mu must not be held.
+func (c *resultCache) put(key string, result domain.LinkResult, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, result, now)
+}
+
+// store caches result for key, making room for it if the cache is full.
+// c.mu must be held.
+func (c *resultCache) store(key string, result domain.LinkResult, now time.Time) {
+	if !cacheable(result) {
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		// Still full, drop any entry.
+		for k := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = cacheEntry{result: result, expires: now.Add(c.ttl)}
+}
+
+// cacheable reports whether result comes from an actual check of the link.
+func cacheable(result domain.LinkResult) bool {
+	return result.Status != domain.StatusUnknown && result.Status != domain.StatusCircuitOpen
+}
+
+// cacheKey identifies the checks of link made with policy.
+func cacheKey(link string, policy domain.Availability) string {
+	return fmt.Sprintf("%s %v %t %t", withScheme(link), policy.Statuses, policy.FollowRedirects, policy.AuthAvailable)
+}
+
+// checkCached checks link, reusing a fresh cached result or the result of a
+// concurrent check of the same link unless noCache is set. The result is
+// marked with where it came from.
+func (s *Service) checkCached(ctx context.Context, link string, policy domain.Availability, noCache bool) domain.LinkResult {
+	if s.cache == nil {
+		return s.check(ctx, link, policy)
+	}
+
+	key := cacheKey(link, policy)
+
+	if noCache {
+		result := s.check(ctx, link, policy)
+		s.cache.put(key, result, time.Now())
+
+		result.Cache = domain.CacheBypass
+		return result
+	}
+
+	for {
+		result, call, leader := s.cache.lookup(key, time.Now())
+		if call == nil {
+			result.Cache = domain.CacheHit
+			return result
+		}
+
+		if leader {
+			result = s.check(ctx, link, policy)
+			s.cache.finish(key, call, result, time.Now())
+
+			result.Cache = domain.CacheMiss
+			return result
+		}
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return domain.LinkResult{Status: domain.StatusUnknown}
+		}
+
+		// The check was aborted by the context of its caller, not ours.
+		if call.result.Status == domain.StatusUnknown {
+			continue
+		}
+
+		result = call.result
+		result.Cache = domain.CacheHit
+		return result
+	}
+}
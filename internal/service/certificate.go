@@ -0,0 +1,126 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// certificateError is returned from the TLS handshake when the peer
+// certificates fail verification. It keeps the certificates, so that they can
+// be reported along with the failure.
+type certificateError struct {
+	certs      []*x509.Certificate
+	serverName string
+	err        error
+}
+
+func (e *certificateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *certificateError) Unwrap() error {
+	return e.err
+}
+
+// newTLSConfig returns the TLS configuration of the transport. Certificates
+// are verified in VerifyConnection instead of by crypto/tls, which discards
+// them when verification fails.
+func (s *Service) newTLSConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+
+	skipVerify := cfg.InsecureSkipVerify
+	roots := cfg.RootCAs
+
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if skipVerify {
+			return nil
+		}
+
+		err := verifyCertificates(cs.PeerCertificates, cs.ServerName, roots)
+		if err != nil {
+			return &certificateError{
+				certs:      cs.PeerCertificates,
+				serverName: cs.ServerName,
+				err:        err,
+			}
+		}
+
+		return nil
+	}
+
+	return cfg
+}
+
+// verifyCertificates verifies the chain presented by serverName the way
+// crypto/tls does. Nil roots stand for the system roots.
+func verifyCertificates(certs []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	if len(certs) == 0 {
+		return errors.New("no peer certificates")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
+
+// inspectCertificates describes the chain presented by serverName.
+func (s *Service) inspectCertificates(certs []*x509.Certificate, serverName string, now time.Time) *domain.TLSInfo {
+	if len(certs) == 0 {
+		return nil
+	}
+
+	info := &domain.TLSInfo{
+		Chain: make([]domain.Certificate, 0, len(certs)),
+	}
+
+	for _, cert := range certs {
+		sans := append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+
+		info.Chain = append(info.Chain, domain.Certificate{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			SANs:     sans,
+			NotAfter: cert.NotAfter.UTC(),
+		})
+	}
+
+	leaf := certs[0]
+	untilExpiry := leaf.NotAfter.Sub(now)
+
+	info.DaysToExpiry = int(math.Floor(untilExpiry.Hours() / 24))
+	info.Expiring = s.certExpiryWindow > 0 && untilExpiry < s.certExpiryWindow
+	info.HostnameMismatch = leaf.VerifyHostname(serverName) != nil
+
+	var roots *x509.CertPool
+	if s.tlsConfig != nil {
+		roots = s.tlsConfig.RootCAs
+	}
+
+	err := verifyCertificates(certs, serverName, roots)
+	if err != nil {
+		info.VerifyError = err.Error()
+	}
+
+	return info
+}
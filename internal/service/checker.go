@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// defaultScheme is assumed for links given without a scheme.
+const defaultScheme = "https"
+
+var (
+	errUnsupportedScheme = errors.New("unsupported scheme")
+	errInvalidLink       = errors.New("invalid link")
+)
+
+// Checker makes a single attempt to check a link of the schemes it is
+// registered for. A failed check is reported as an error, the returned result
+// keeps whatever details were gathered before the failure. Retries, the
+// connection limit and the resulting status are handled by the Service.
+type Checker interface {
+	Check(ctx context.Context, link string, policy domain.Availability) (domain.LinkResult, error)
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context, link string, policy domain.Availability) (domain.LinkResult, error)
+
+func (f CheckerFunc) Check(ctx context.Context, link string, policy domain.Availability) (domain.LinkResult, error) {
+	return f(ctx, link, policy)
+}
+
+// WithChecker registers checker for links with the given scheme, replacing
+// the built-in one if any.
+func WithChecker(scheme string, checker Checker) Option {
+	return func(s *Service) {
+		s.checkers[strings.ToLower(scheme)] = checker
+	}
+}
+
+// defaultCheckers returns the built-in checkers by scheme.
+func (s *Service) defaultCheckers() map[string]Checker {
+	return map[string]Checker{
+		"http":  CheckerFunc(s.checkHTTP),
+		"https": CheckerFunc(s.checkHTTP),
+		"tcp":   CheckerFunc(s.checkTCP),
+		"dns":   CheckerFunc(s.checkDNS),
+		"tls":   CheckerFunc(s.checkTLS),
+	}
+}
+
+// checker returns the checker registered for the scheme of link, along with
+// the link to pass to it. Links without a scheme are checked as https links.
+func (s *Service) checker(link string) (Checker, string, error) {
+	link = withScheme(link)
+	scheme, _, _ := strings.Cut(link, "://")
+
+	checker, ok := s.checkers[strings.ToLower(scheme)]
+	if !ok {
+		return nil, link, fmt.Errorf("%w: %s", errUnsupportedScheme, scheme)
+	}
+
+	return checker, link, nil
+}
+
+// withScheme prefixes link with the default scheme if it has none.
+func withScheme(link string) string {
+	if !strings.Contains(link, "://") {
+		return defaultScheme + "://" + link
+	}
+
+	return link
+}
+
+// statusError is returned by the http checker for responses the availability
+// policy does not accept.
+type statusError struct {
+	statusCode int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.statusCode)
+}
+
+// checkHTTP requests link over http(s) and checks the response against the
+// availability policy.
+func (s *Service) checkHTTP(ctx context.Context, link string, policy domain.Availability) (domain.LinkResult, error) {
+	result, retryAfter, err := s.ping(ctx, link, policy.FollowRedirects)
+	if err != nil {
+		return result, err
+	}
+
+	if !policy.Accepts(result.StatusCode) {
+		return result, &statusError{statusCode: result.StatusCode, retryAfter: retryAfter}
+	}
+
+	return result, nil
+}
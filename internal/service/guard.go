@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+var errBlocked = errors.New("address is blocked")
+
+// CIDRs is a list of address ranges, set from the environment as comma
+// separated CIDRs, e.g. "10.0.0.0/8,fd00::/8".
+type CIDRs []netip.Prefix
+
+// SetValue implements cleanenv.Setter.
+func (c *CIDRs) SetValue(value string) error {
+	var prefixes CIDRs
+
+	for part := range strings.SplitSeq(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return fmt.Errorf("invalid cidr %q: %w", part, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*c = prefixes
+	return nil
+}
+
+func (c CIDRs) contains(addr netip.Addr) bool {
+	for _, prefix := range c {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reservedPrefixes are the ranges that are not reachable on the public
+// internet but are not covered by the netip predicates.
+var reservedPrefixes = CIDRs{
+	// "This network", such as 0.1.2.3.
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Carrier-grade NAT, including the 100.100.100.200 metadata endpoint.
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// Benchmarking.
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// Reserved for future use.
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// Limited broadcast.
+	netip.MustParsePrefix("255.255.255.255/32"),
+	// NAT64, which reaches arbitrary IPv4 addresses through a gateway.
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+var (
+	nat64Prefix  = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour    = netip.MustParsePrefix("2002::/16")
+	teredoPrefix = netip.MustParsePrefix("2001::/32")
+)
+
+// addressGuard decides which addresses checks may connect to. It is applied
+// by dialContext to every connection, including the ones made for redirects.
+type addressGuard struct {
+	blockPrivate bool
+	blocked      CIDRs
+	allowed      CIDRs
+}
+
+func (g *addressGuard) enabled() bool {
+	return g.blockPrivate || len(g.blocked) > 0
+}
+
+// allows reports whether addr may be connected to. The allowlist takes
+// precedence over both the built-in and the configured blocks. IPv6
+// addresses embedding an IPv4 address are blocked if either one is.
+func (g *addressGuard) allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if g.allowed.contains(addr) {
+		return true
+	}
+
+	if g.blocks(addr) {
+		return false
+	}
+
+	embedded, ok := embeddedIPv4(addr)
+	return !ok || !g.blocks(embedded)
+}
+
+func (g *addressGuard) blocks(addr netip.Addr) bool {
+	return g.blockPrivate && isInternal(addr) || g.blocked.contains(addr)
+}
+
+// isInternal reports whether addr is not a public unicast address: loopback,
+// link-local, including cloud metadata endpoints, private, unspecified,
+// multicast or reserved.
+func isInternal(addr netip.Addr) bool {
+	return addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		reservedPrefixes.contains(addr)
+}
+
+// embeddedIPv4 returns the IPv4 address that traffic to the IPv6 address addr
+// ends up at through NAT64, 6to4 or Teredo.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() {
+		return netip.Addr{}, false
+	}
+
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:16])), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	case teredoPrefix.Contains(addr):
+		// The client address is stored inverted.
+		return netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}), true
+	default:
+		return netip.Addr{}, false
+	}
+}
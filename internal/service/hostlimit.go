@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIdleHosts is the number of hosts the limiter tracks before it starts
+// forgetting idle ones.
+const maxIdleHosts = 1024
+
+// hostLimiter keeps checks polite towards a single host: a token bucket paces
+// the checks started against it and a semaphore bounds the ones running at
+// once. A host that answered 429 is left alone until its Retry-After passes.
+type hostLimiter struct {
+	rate     float64
+	burst    float64
+	maxConns int
+	mu       *sync.Mutex
+	hosts    map[string]*hostState
+}
+
+type hostState struct {
+	tokens    float64
+	last      time.Time
+	notBefore time.Time
+	conns     chan struct{}
+}
+
+func newHostLimiter(cfg *Config) *hostLimiter {
+	return &hostLimiter{
+		rate:     max(cfg.HostRateLimit, 0),
+		burst:    float64(max(cfg.HostBurst, 1)),
+		maxConns: max(cfg.HostMaxConnections, 0),
+		mu:       &sync.Mutex{},
+		hosts:    make(map[string]*hostState),
+	}
+}
+
+// acquire waits until a check against host may start. The returned function
+// must be called once the check is done. An error is returned only if ctx is
+// done first. Links without a host are not limited.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	release := func() {}
+	if host == "" {
+		return release, nil
+	}
+
+	state := l.state(host)
+	if state.conns != nil {
+		select {
+		case state.conns <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		release = func() { <-state.conns }
+	}
+
+	for {
+		wait := l.take(state, time.Now())
+		if wait <= 0 {
+			return release, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// take takes a token from the bucket of state, or returns how long to wait
+// before trying again.
+func (l *hostLimiter) take(state *hostState, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(state.notBefore) {
+		return state.notBefore.Sub(now)
+	}
+
+	if l.rate == 0 {
+		return 0
+	}
+
+	state.tokens = min(state.tokens+now.Sub(state.last).Seconds()*l.rate, l.burst)
+	state.last = now
+
+	if state.tokens >= 1 {
+		state.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - state.tokens) / l.rate * float64(time.Second))
+}
+
+// backoff holds further checks against host for d.
+func (l *hostLimiter) backoff(host string, d time.Duration) {
+	if d <= 0 || host == "" {
+		return
+	}
+
+	state := l.state(host)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if notBefore := time.Now().Add(d); notBefore.After(state.notBefore) {
+		state.notBefore = notBefore
+	}
+}
+
+func (l *hostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.hosts[host]
+	if ok {
+		return state
+	}
+
+	if len(l.hosts) >= maxIdleHosts {
+		l.forgetIdle(time.Now())
+	}
+
+	state = &hostState{
+		tokens: l.burst,
+		last:   time.Now(),
+	}
+	if l.maxConns > 0 {
+		state.conns = make(chan struct{}, l.maxConns)
+	}
+
+	l.hosts[host] = state
+	return state
+}
+
+// forgetIdle drops the hosts that have no running checks, a full bucket and
+// no pending backoff, they behave exactly as new ones. l.mu must be held.
+func (l *hostLimiter) forgetIdle(now time.Time) {
+	for host, state := range l.hosts {
+		if len(state.conns) > 0 || now.Before(state.notBefore) {
+			continue
+		}
+
+		if l.rate > 0 && state.tokens+now.Sub(state.last).Seconds()*l.rate < l.burst {
+			continue
+		}
+
+		delete(l.hosts, host)
+	}
+}
+
+// linkHost returns the host a checker link is made against, in lower case.
+func linkHost(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// failedJobRetention is how long Job reports a job whose record could not be
+// saved.
+const failedJobRetention = time.Hour
+
+// job checks the links of entries. Results are kept by normalized link, and
+// shared by the entries of the same link.
+type job struct {
+	mu      *sync.Mutex
+	id      int64
+	entries []domain.LinkEntry
+	links   []string
+	opts    Options
+	state   string
+	results map[string]domain.LinkResult
+	err     error
+}
+
+func newJob(id int64, entries []domain.LinkEntry, opts Options) *job {
+	links := checkedLinks(entries)
+
+	return &job{
+		mu:      &sync.Mutex{},
+		id:      id,
+		entries: entries,
+		links:   links,
+		opts:    opts,
+		state:   domain.JobStateQueued,
+		results: make(map[string]domain.LinkResult, len(links)),
+	}
+}
+
+func (j *job) setState(state string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.state = state
+	j.err = err
+}
+
+func (j *job) setResult(link string, result domain.LinkResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.results[link] = result
+}
+
+// pending returns the links that have no result yet.
+func (j *job) pending() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var pending []string
+	for _, link := range j.links {
+		if _, ok := j.results[link]; !ok {
+			pending = append(pending, link)
+		}
+	}
+
+	return pending
+}
+
+func (j *job) resultsCopy() map[string]domain.LinkResult {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	results := make(map[string]domain.LinkResult, len(j.results))
+	for link, result := range j.results {
+		results[link] = result
+	}
+
+	return results
+}
+
+func (j *job) snapshot() *domain.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	checked := 0
+	for _, entry := range j.entries {
+		if _, ok := j.results[entry.Normalized]; ok {
+			checked++
+		}
+	}
+
+	snapshot := &domain.Job{
+		ID:      j.id,
+		State:   j.state,
+		Total:   len(j.entries),
+		Checked: checked,
+		Links:   withResults(j.entries, j.results, domain.LinkResult{Status: domain.StatusUnknown}),
+	}
+
+	if j.err != nil {
+		snapshot.Error = j.err.Error()
+	}
+
+	return snapshot
+}
+
+// jobQueue is an unbounded FIFO of jobs waiting for a worker. Submitted jobs
+// are bounded by reserving their slot up front, see reserve.
+type jobQueue struct {
+	mu    *sync.Mutex
+	items []*job
+	// reserved counts the slots taken by jobs being submitted.
+	reserved int
+	ready    chan struct{}
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{
+		mu:    &sync.Mutex{},
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// reserve takes a slot for a job about to be pushed with pushReserved,
+// unless limit jobs are already queued or reserved. Zero means no limit.
+func (q *jobQueue) reserve(limit int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if limit > 0 && len(q.items)+q.reserved >= limit {
+		return false
+	}
+
+	q.reserved++
+	return true
+}
+
+// release gives back a slot taken by reserve for a job that is not pushed.
+func (q *jobQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.reserved--
+}
+
+// pushReserved pushes j into the slot reserved for it.
+func (q *jobQueue) pushReserved(j *job) {
+	q.mu.Lock()
+	q.reserved--
+	q.items = append(q.items, j)
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+func (q *jobQueue) push(j *job) {
+	q.mu.Lock()
+	q.items = append(q.items, j)
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+// pop blocks until a job is available or ctx is done.
+func (q *jobQueue) pop(ctx context.Context) (*job, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			j := q.items[0]
+			q.items = q.items[1:]
+			remaining := len(q.items)
+			q.mu.Unlock()
+
+			if remaining > 0 {
+				q.notify()
+			}
+
+			return j, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (q *jobQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Submit persists a new job for links and queues it. Links are normalized as
+// by Process. The returned job ID is the links_num of the record that will be
+// saved once the job is done. If the application is stopping, the job is only
+// persisted and runs after restart.
+func (s *Service) Submit(serverCtx context.Context, links []string, opts Options) (*domain.Job, error) {
+	if !s.queue.reserve(s.queueSize) {
+		return nil, ErrQueueFull
+	}
+
+	id, err := s.nextID()
+	if err != nil {
+		s.queue.release()
+		return nil, err
+	}
+
+	entries := s.newEntries(links)
+
+	_, err = s.saveTempRecord(id, entries, nil, opts)
+	if err != nil {
+		s.queue.release()
+		return nil, err
+	}
+
+	j := newJob(id, entries, opts)
+
+	select {
+	case <-serverCtx.Done():
+		s.queue.release()
+		s.registerJob(j)
+		return j.snapshot(), ErrAppStopped
+
+	default:
+	}
+
+	s.registerJob(j)
+	s.queue.pushReserved(j)
+
+	s.logger.Info("job queued", zap.Int64("id", id))
+	return j.snapshot(), nil
+}
+
+// Job reports the progress of the job with the given ID. Finished jobs are
+// looked up in the repository.
+func (s *Service) Job(id int64) (*domain.Job, error) {
+	s.jobsMu.Lock()
+	j, ok := s.jobs[id]
+	s.jobsMu.Unlock()
+
+	if ok {
+		return j.snapshot(), nil
+	}
+
+	rec, err := s.repository.GetRecord(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get record: %w", err)
+	}
+
+	return &domain.Job{
+		ID:      rec.ID,
+		State:   domain.JobStateDone,
+		Total:   len(rec.Links),
+		Checked: len(rec.Links),
+		Links:   rec.Links,
+	}, nil
+}
+
+// RunJobs executes queued jobs until ctx is done. Jobs still in the queue at
+// that point stay in temp storage and are picked up by ProcessTempRecords on
+// the next start.
+func (s *Service) RunJobs(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for range s.jobWorkers {
+		wg.Go(func() {
+			for {
+				j, ok := s.queue.pop(ctx)
+				if !ok {
+					return
+				}
+
+				s.runJob(j)
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func (s *Service) runJob(j *job) {
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
+	j.setState(domain.JobStateRunning, nil)
+
+	_, err := s.checkLinks(s.drainCtx, j.pending(), j.opts, j.setResult)
+	if err != nil {
+		j.setState(domain.JobStateQueued, ErrAppStopped)
+
+		// The temp record saved on submit is replaced by one that keeps the
+		// results checked so far. The old one stays if saving fails.
+		_, err = s.saveTempRecord(j.id, j.entries, j.resultsCopy(), j.opts)
+		if err != nil {
+			s.logger.Error("failed to save unfinished job", zap.Int64("id", j.id), zap.Error(err))
+		}
+
+		return
+	}
+
+	rec := newRecord(j.id, withResults(j.entries, j.resultsCopy(), domain.LinkResult{Status: domain.StatusUnknown}), j.opts)
+
+	err = s.repository.SaveRecord(rec)
+	if err != nil {
+		j.setState(domain.JobStateFailed, err)
+		s.logger.Error("failed to save job record", zap.Int64("id", j.id), zap.Error(err))
+
+		// The temp record is kept, so the job runs again on the next start.
+		// Until then its failure is reported for a while only.
+		time.AfterFunc(failedJobRetention, func() {
+			s.forgetJob(j.id)
+		})
+
+		return
+	}
+
+	j.setState(domain.JobStateDone, nil)
+	s.notify(rec)
+
+	err = s.repository.DeleteTempRecord(j.id)
+	if err != nil {
+		s.logger.Error("failed to delete temp record", zap.Int64("id", j.id), zap.Error(err))
+	}
+
+	s.forgetJob(j.id)
+
+	s.logger.Info("job done", zap.Int64("id", j.id))
+}
+
+// saveTempRecord persists entries under id as a temp record. Links missing
+// from results are saved with the unknown status.
+func (s *Service) saveTempRecord(id int64, entries []domain.LinkEntry, results map[string]domain.LinkResult, opts Options) (*domain.Record, error) {
+	rec := newRecord(id, withResults(entries, results, domain.LinkResult{Status: domain.StatusUnknown}), opts)
+
+	err := s.repository.SaveTempRecord(rec)
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Error(err))
+		return nil, fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	return rec, nil
+}
+
+func (s *Service) registerJob(j *job) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	s.jobs[j.id] = j
+}
+
+// forgetJob stops tracking the job with the given ID, its progress is then
+// looked up in the repository.
+func (s *Service) forgetJob(id int64) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	delete(s.jobs, id)
+}
+
+func (s *Service) enqueue(j *job) {
+	s.registerJob(j)
+	s.queue.push(j)
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+var (
+	ErrMonitorNotFound = errors.New("monitor not found")
+	ErrMonitorExists   = errors.New("monitor already exists")
+	// ErrInvalidMonitor wraps the reason a monitor cannot be created.
+	ErrInvalidMonitor = errors.New("invalid monitor")
+)
+
+// idleWait is how long the scheduler sleeps when no monitor is due.
+const idleWait = time.Hour
+
+// monitor is a monitor along with its parsed schedule.
+type monitor struct {
+	domain.Monitor
+	schedule schedule
+	running  bool
+}
+
+// monitorSet holds the monitors run by the scheduler.
+type monitorSet struct {
+	mu       *sync.Mutex
+	monitors map[int64]*monitor
+	lastID   int64
+	// changed wakes the scheduler when monitors are added, removed or done
+	// running.
+	changed chan struct{}
+}
+
+func newMonitorSet() *monitorSet {
+	return &monitorSet{
+		mu:       &sync.Mutex{},
+		monitors: make(map[int64]*monitor),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+func (ms *monitorSet) notify() {
+	select {
+	case ms.changed <- struct{}{}:
+	default:
+	}
+}
+
+// LoadMonitors restores the monitors saved by the previous run. Runs missed
+// while the application was down are made once, as soon as RunMonitors
+// starts.
+func (s *Service) LoadMonitors() error {
+	monitors, err := s.repository.LoadMonitors()
+	if err != nil {
+		s.logger.Error("failed to load monitors", zap.Error(err))
+		return fmt.Errorf("failed to load monitors: %w", err)
+	}
+
+	// IDs of deleted monitors are not reused, their runs' records keep
+	// pointing to them.
+	lastID, err := s.repository.LoadLastMonitorID()
+	if err != nil {
+		s.logger.Error("failed to load last monitor id", zap.Error(err))
+		return fmt.Errorf("failed to load last monitor id: %w", err)
+	}
+
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	s.monitors.lastID = max(s.monitors.lastID, lastID)
+
+	for _, m := range monitors {
+		s.monitors.lastID = max(s.monitors.lastID, m.ID)
+
+		// Schedules are checked against the minimum interval on creation
+		// only, so that monitors survive a stricter configuration.
+		sched, err := parseSchedule(m.Schedule, 0)
+		if err != nil {
+			s.logger.Error("skipping monitor with invalid schedule", zap.Int64("id", m.ID), zap.Error(err))
+			continue
+		}
+
+		s.monitors.monitors[m.ID] = &monitor{Monitor: m, schedule: sched}
+	}
+
+	s.logger.Info("successfully loaded monitors", zap.Int("count", len(monitors)))
+	return nil
+}
+
+// CreateMonitor saves a new monitor of links checked with opts on the given
+// schedule. Its first run is due at the first time of the schedule.
+func (s *Service) CreateMonitor(name string, links []string, spec string, opts Options) (*domain.Monitor, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty name", ErrInvalidMonitor)
+	}
+
+	if len(links) == 0 {
+		return nil, fmt.Errorf("%w: no links", ErrInvalidMonitor)
+	}
+
+	sched, err := parseSchedule(spec, s.monitorMinInterval)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMonitor, err)
+	}
+
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	for _, m := range s.monitors.monitors {
+		if m.Name == name {
+			return nil, fmt.Errorf("%w: %s", ErrMonitorExists, name)
+		}
+	}
+
+	now := time.Now().UTC()
+	m := &monitor{
+		Monitor: domain.Monitor{
+			ID:           s.monitors.lastID + 1,
+			Name:         name,
+			Links:        slices.Clone(links),
+			Schedule:     strings.TrimSpace(spec),
+			CallbackURL:  opts.CallbackURL,
+			Availability: opts.Availability,
+			CreatedAt:    now,
+			NextRunAt:    sched.next(now),
+			Runs:         []domain.MonitorRun{},
+		},
+		schedule: sched,
+	}
+
+	err = s.repository.SaveMonitor(&m.Monitor)
+	if err != nil {
+		s.logger.Error("failed to save monitor", zap.String("name", name), zap.Error(err))
+		return nil, fmt.Errorf("failed to save monitor: %w", err)
+	}
+
+	s.monitors.lastID = m.ID
+	s.monitors.monitors[m.ID] = m
+	s.monitors.notify()
+
+	s.logger.Info("monitor created", zap.Int64("id", m.ID), zap.String("name", name), zap.String("schedule", m.Schedule))
+	return m.snapshot(), nil
+}
+
+// Monitors returns all monitors, ordered by ID.
+func (s *Service) Monitors() []domain.Monitor {
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	monitors := make([]domain.Monitor, 0, len(s.monitors.monitors))
+	for _, m := range s.monitors.monitors {
+		monitors = append(monitors, *m.snapshot())
+	}
+
+	slices.SortFunc(monitors, func(a, b domain.Monitor) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return monitors
+}
+
+// Monitor returns the monitor with the given ID along with its latest runs.
+func (s *Service) Monitor(id int64) (*domain.Monitor, error) {
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	m, ok := s.monitors.monitors[id]
+	if !ok {
+		return nil, ErrMonitorNotFound
+	}
+
+	return m.snapshot(), nil
+}
+
+// DeleteMonitor stops and removes the monitor with the given ID. The records
+// of its past runs are kept.
+func (s *Service) DeleteMonitor(id int64) error {
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	if _, ok := s.monitors.monitors[id]; !ok {
+		return ErrMonitorNotFound
+	}
+
+	err := s.repository.DeleteMonitor(id)
+	if err != nil {
+		s.logger.Error("failed to delete monitor", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete monitor: %w", err)
+	}
+
+	delete(s.monitors.monitors, id)
+	s.monitors.notify()
+
+	s.logger.Info("monitor deleted", zap.Int64("id", id))
+	return nil
+}
+
+// RunMonitors runs the monitors when they are due until ctx is done. A
+// monitor still running when its next run is due skips that run.
+func (s *Service) RunMonitors(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		next := s.startDueMonitors(ctx, time.Now())
+
+		wait := idleWait
+		if !next.IsZero() {
+			wait = max(time.Until(next), 0)
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.monitors.changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// startDueMonitors starts the runs of the monitors due at now, and returns
+// the time the next monitor is due, or the zero time if none is.
+func (s *Service) startDueMonitors(ctx context.Context, now time.Time) time.Time {
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	var next time.Time
+	for _, m := range s.monitors.monitors {
+		if m.running || m.NextRunAt.IsZero() {
+			continue
+		}
+
+		if m.NextRunAt.After(now) {
+			if next.IsZero() || m.NextRunAt.Before(next) {
+				next = m.NextRunAt
+			}
+
+			continue
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		m.running = true
+		m.NextRunAt = m.schedule.next(now)
+
+		// Counted before the run starts, so that Shutdown waits for it.
+		s.inflight.Add(1)
+		go s.runMonitor(m.ID, *m.snapshot())
+	}
+
+	return next
+}
+
+// runMonitor checks the links of m and saves them as a record tied to it.
+// A run interrupted by shutdown is not saved, and is made again on the next
+// start.
+func (s *Service) runMonitor(id int64, m domain.Monitor) {
+	defer s.inflight.Add(-1)
+
+	startedAt := time.Now().UTC()
+
+	rec, err := s.checkMonitor(&m)
+	if err != nil {
+		s.logger.Warn("monitor run failed", zap.Int64("monitor_id", id), zap.Error(err))
+	}
+
+	s.monitors.mu.Lock()
+	defer s.monitors.mu.Unlock()
+
+	current, ok := s.monitors.monitors[id]
+	if !ok {
+		return
+	}
+
+	current.running = false
+	s.monitors.notify()
+
+	if rec == nil {
+		return
+	}
+
+	current.addRun(rec, startedAt, s.monitorHistory)
+
+	// Runs that were due while this one was running are skipped.
+	if !current.NextRunAt.IsZero() && current.NextRunAt.Before(time.Now()) {
+		current.NextRunAt = current.schedule.next(time.Now())
+	}
+
+	err = s.repository.SaveMonitor(current.snapshot())
+	if err != nil {
+		s.logger.Error("failed to save monitor", zap.Int64("monitor_id", id), zap.Error(err))
+	}
+
+	s.logger.Info("monitor run done", zap.Int64("monitor_id", id), zap.Int64("links_num", rec.ID), zap.Bool("up", current.Runs[len(current.Runs)-1].Up()))
+}
+
+// checkMonitor checks the links of m and saves the record of the run.
+func (s *Service) checkMonitor(m *domain.Monitor) (*domain.Record, error) {
+	id, err := s.nextID()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := Options{
+		CallbackURL:  m.CallbackURL,
+		Availability: m.Availability,
+	}
+
+	entries := s.newEntries(m.Links)
+
+	results, err := s.checkLinks(s.drainCtx, checkedLinks(entries), opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := newRecord(id, withResults(entries, results, domain.LinkResult{Status: domain.StatusUnknown}), opts)
+	rec.MonitorID = m.ID
+
+	err = s.repository.SaveRecord(rec)
+	if err != nil {
+		s.logger.Error("failed to save record", zap.Int64("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to save record: %w", err)
+	}
+
+	s.notify(rec)
+
+	return rec, nil
+}
+
+// addRun appends the run saved as rec, keeping the latest history runs, and
+// updates the uptime.
+func (m *monitor) addRun(rec *domain.Record, startedAt time.Time, history int) {
+	run := domain.MonitorRun{
+		ID:        rec.ID,
+		StartedAt: startedAt,
+		Total:     len(rec.Links),
+	}
+
+	for _, entry := range rec.Links {
+		if entry.Result.Status == domain.StatusAvailable || entry.Result.Status == domain.StatusRedirected {
+			run.Available++
+		}
+	}
+
+	m.Runs = append(m.Runs, run)
+	if len(m.Runs) > history {
+		m.Runs = slices.Clone(m.Runs[len(m.Runs)-history:])
+	}
+
+	up := 0
+	for _, r := range m.Runs {
+		if r.Up() {
+			up++
+		}
+	}
+
+	m.Uptime = float64(up) / float64(len(m.Runs))
+}
+
+// snapshot returns a copy of the monitor that is safe to use without the
+// lock.
+func (m *monitor) snapshot() *domain.Monitor {
+	snapshot := m.Monitor
+	snapshot.Links = slices.Clone(m.Links)
+	snapshot.Runs = slices.Clone(m.Runs)
+
+	return &snapshot
+}
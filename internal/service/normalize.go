@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var errEmptyLink = errors.New("empty link")
+
+// defaultPorts are dropped from normalized links of their scheme.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"tls":   "443",
+}
+
+// NormalizeLink validates link and returns its canonical form, so that
+// different spellings of the same link are checked once:
+//   - links without a scheme get the default https scheme,
+//   - the scheme and the host are lower case, internationalized host names
+//     are converted to punycode,
+//   - default ports are dropped,
+//   - an empty path of http(s) links becomes "/", other paths are kept as is,
+//     including trailing slashes, as servers may tell them apart,
+//   - fragments are dropped, since they are never sent to the server.
+//
+// Links must have a host and a scheme a checker is registered for. tcp://
+// links must have a port and the expect parameter of dns:// links must list
+// IP addresses.
+func (s *Service) NormalizeLink(link string) (string, error) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return "", errEmptyLink
+	}
+
+	u, err := url.Parse(withScheme(link))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidLink, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := s.checkers[u.Scheme]; !ok {
+		return "", fmt.Errorf("%w: %s", errUnsupportedScheme, u.Scheme)
+	}
+
+	switch u.Scheme {
+	case "tcp", "tls":
+		_, err = parseProbeLink(u.String(), defaultPorts[u.Scheme])
+	case "dns":
+		_, err = parseExpectedAddresses(u)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("%w: invalid port %q", errInvalidLink, port)
+		}
+
+		port = strconv.Itoa(n)
+	}
+
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	u.Host = host
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	if u.Path == "" && (u.Scheme == "http" || u.Scheme == "https") {
+		u.Path = "/"
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+// normalizeHost lower cases host and converts it to its ASCII form. IP
+// addresses are returned in their canonical form.
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: missing host", errInvalidLink)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid host %q: %w", errInvalidLink, host, err)
+	}
+
+	return strings.ToLower(ascii), nil
+}
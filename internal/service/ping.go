@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// ping requests the http(s) link and returns the result along with the
+// Retry-After delay asked by the server, if any. Redirects are followed only
+// if followRedirects is set, and recorded either way.
+func (s *Service) ping(ctx context.Context, link string, followRedirects bool) (domain.LinkResult, time.Duration, error) {
+	result, retryAfter, err := s.do(ctx, http.MethodHead, link, followRedirects)
+	if err == nil {
+		return result, retryAfter, nil
+	}
+
+	result, retryAfter, err = s.do(ctx, http.MethodGet, link, followRedirects)
+	if err != nil {
+		return result, 0, fmt.Errorf("failed to ping link: %w", err)
+	}
+
+	return result, retryAfter, nil
+}
+
+func (s *Service) do(ctx context.Context, method string, link string, followRedirects bool) (domain.LinkResult, time.Duration, error) {
+	result := domain.LinkResult{
+		Method:    method,
+		CheckedAt: time.Now().UTC(),
+	}
+
+	chain := &redirectChain{
+		follow: followRedirects,
+		limit:  s.maxRedirects,
+	}
+
+	start := time.Now()
+	timing := newTiming(start)
+
+	req, err := http.NewRequestWithContext(withTiming(withRedirectChain(ctx, chain), timing), method, link, nil)
+	if err != nil {
+		return result, 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	total := time.Since(start)
+	result.LatencyMs = total.Milliseconds()
+	result.Timing = timing.result(total)
+	result.Redirects = chain.hops
+	if err != nil {
+		var certErr *certificateError
+		if errors.As(err, &certErr) {
+			result.TLS = s.inspectCertificates(certErr.certs, certErr.serverName, time.Now())
+		}
+
+		return result, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS != nil {
+		result.TLS = s.inspectCertificates(resp.TLS.PeerCertificates, resp.Request.URL.Hostname(), time.Now())
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+
+	return result, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), nil
+}
+
+// classifyError maps a transport error to one of the domain error classes.
+func classifyError(err error) string {
+	var (
+		dnsErr         *net.DNSError
+		netErr         net.Error
+		opErr          *net.OpError
+		verifyErr      *tls.CertificateVerificationError
+		recordErr      tls.RecordHeaderError
+		alertErr       tls.AlertError
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.Is(err, errUnsupportedScheme), errors.Is(err, errInvalidLink):
+		return domain.ErrorClassInvalid
+	case errors.Is(err, errDNSMismatch):
+		return domain.ErrorClassDNS
+	case errors.Is(err, errTooManyRedirects), errors.Is(err, errRedirectLoop):
+		return domain.ErrorClassRedirect
+	case errors.As(err, &dnsErr):
+		return domain.ErrorClassDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return domain.ErrorClassTimeout
+	case errors.As(err, &verifyErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return domain.ErrorClassTLS
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return domain.ErrorClassConnect
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF):
+		return domain.ErrorClassReset
+	default:
+		return domain.ErrorClassHTTP
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+var errDNSMismatch = errors.New("resolved addresses do not match")
+
+// checkTCP connects to the host and port of a tcp://host:port link.
+func (s *Service) checkTCP(ctx context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+	result := domain.LinkResult{
+		Method:    "tcp",
+		CheckedAt: time.Now().UTC(),
+	}
+
+	u, err := parseProbeLink(link, "")
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := s.probeContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	timing := newTiming(start)
+
+	conn, err := s.dialContext(withTiming(ctx, timing), "tcp", u.Host)
+	s.finishProbe(&result, timing, start)
+	if err != nil {
+		return result, err
+	}
+
+	_ = conn.Close()
+
+	return result, nil
+}
+
+// checkDNS resolves the host of a dns://host link. Addresses listed in the
+// expect query parameter, e.g. dns://example.com?expect=192.0.2.1,192.0.2.2,
+// must all be among the resolved ones.
+func (s *Service) checkDNS(ctx context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+	result := domain.LinkResult{
+		Method:    "dns",
+		CheckedAt: time.Now().UTC(),
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return result, fmt.Errorf("%w: %s", errInvalidLink, link)
+	}
+
+	expected, err := parseExpectedAddresses(u)
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := s.probeContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := s.resolver.LookupIPAddr(ctx, u.Hostname())
+	elapsed := time.Since(start)
+
+	result.LatencyMs = elapsed.Milliseconds()
+	result.Timing = &domain.Timing{DNSMs: elapsed.Milliseconds(), TotalMs: elapsed.Milliseconds()}
+	if err != nil {
+		return result, err
+	}
+
+	if len(addrs) == 0 {
+		return result, &net.DNSError{Err: "no such host", Name: u.Hostname(), IsNotFound: true}
+	}
+
+	// Addresses the guard blocks are left out, so that dns:// links cannot
+	// be used to map internal networks.
+	for _, addr := range addrs {
+		ip, ok := netip.AddrFromSlice(addr.IP)
+		if ok && s.guard.allows(ip) {
+			result.Addresses = append(result.Addresses, addr.IP.String())
+		}
+	}
+
+	if len(result.Addresses) == 0 {
+		return result, fmt.Errorf("%w: %s resolves to blocked addresses only", errBlocked, u.Hostname())
+	}
+
+	for _, ip := range expected {
+		if !slices.Contains(result.Addresses, ip) {
+			return result, fmt.Errorf("%w: %s not in %s", errDNSMismatch, ip, strings.Join(result.Addresses, ","))
+		}
+	}
+
+	return result, nil
+}
+
+// checkTLS makes a TLS handshake with the host of a tls://host[:port] link,
+// port 443 by default, and inspects the presented certificates.
+func (s *Service) checkTLS(ctx context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+	result := domain.LinkResult{
+		Method:    "tls",
+		CheckedAt: time.Now().UTC(),
+	}
+
+	u, err := parseProbeLink(link, "443")
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := s.probeContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	timing := newTiming(start)
+
+	conn, err := s.dialContext(withTiming(ctx, timing), "tcp", u.Host)
+	if err != nil {
+		s.finishProbe(&result, timing, start)
+		return result, err
+	}
+	defer conn.Close()
+
+	cfg := s.newTLSConfig()
+	cfg.ServerName = u.Hostname()
+
+	tlsConn := tls.Client(conn, cfg)
+
+	handshakeStart := time.Now()
+	err = tlsConn.HandshakeContext(ctx)
+	timing.addTLS(time.Since(handshakeStart))
+	s.finishProbe(&result, timing, start)
+
+	if err != nil {
+		var certErr *certificateError
+		if errors.As(err, &certErr) {
+			result.TLS = s.inspectCertificates(certErr.certs, certErr.serverName, time.Now())
+		}
+
+		return result, err
+	}
+
+	result.TLS = s.inspectCertificates(tlsConn.ConnectionState().PeerCertificates, u.Hostname(), time.Now())
+
+	return result, nil
+}
+
+// probeContext bounds a probe by the ping timeout, as the http client does
+// for http checks.
+func (s *Service) probeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.pingTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.pingTimeout)
+}
+
+func (s *Service) finishProbe(result *domain.LinkResult, timing *timing, start time.Time) {
+	total := time.Since(start)
+	result.LatencyMs = total.Milliseconds()
+	result.Timing = timing.result(total)
+}
+
+// parseProbeLink parses a scheme://host:port link. defaultPort is used when
+// the link has no port, if empty the port is required.
+func parseProbeLink(link string, defaultPort string) (*url.URL, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %s", errInvalidLink, link)
+	}
+
+	if u.Port() == "" {
+		if defaultPort == "" {
+			return nil, fmt.Errorf("%w: missing port: %s", errInvalidLink, link)
+		}
+
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	return u, nil
+}
+
+// parseExpectedAddresses returns the addresses listed in the expect query
+// parameter of a dns:// link.
+func parseExpectedAddresses(u *url.URL) ([]string, error) {
+	var expected []string
+	for _, value := range u.Query()["expect"] {
+		for ip := range strings.SplitSeq(value, ",") {
+			addr := net.ParseIP(strings.TrimSpace(ip))
+			if addr == nil {
+				return nil, fmt.Errorf("%w: expected address %q", errInvalidLink, ip)
+			}
+
+			expected = append(expected, addr.String())
+		}
+	}
+
+	return expected, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"link-service/internal/domain"
+)
+
+// defaultMaxRedirects is the number of redirects followed when no limit is
+// configured, as in the default http.Client.
+const defaultMaxRedirects = 10
+
+var (
+	errTooManyRedirects = errors.New("too many redirects")
+	errRedirectLoop     = errors.New("redirect loop")
+)
+
+// redirectChain records the redirects of a single request. It is passed to
+// checkRedirect through the request context.
+type redirectChain struct {
+	follow bool
+	limit  int
+	hops   []domain.Redirect
+}
+
+type redirectChainKey struct{}
+
+func withRedirectChain(ctx context.Context, chain *redirectChain) context.Context {
+	return context.WithValue(ctx, redirectChainKey{}, chain)
+}
+
+// checkRedirect is the http.Client CheckRedirect hook. It records the redirect
+// that led to req and stops at the hop limit or when a URL is visited twice.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	chain, ok := req.Context().Value(redirectChainKey{}).(*redirectChain)
+	if !ok {
+		if len(via) >= defaultMaxRedirects {
+			return errTooManyRedirects
+		}
+
+		return nil
+	}
+
+	if resp := req.Response; resp != nil {
+		chain.hops = append(chain.hops, domain.Redirect{
+			URL:        resp.Request.URL.String(),
+			StatusCode: resp.StatusCode,
+			Location:   resp.Header.Get("Location"),
+		})
+	}
+
+	if !chain.follow {
+		return http.ErrUseLastResponse
+	}
+
+	if len(via) > chain.limit {
+		return fmt.Errorf("%w: stopped after %d redirects", errTooManyRedirects, chain.limit)
+	}
+
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return fmt.Errorf("%w: %s", errRedirectLoop, req.URL)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// retryPolicy decides whether a failed check is attempted again and how long
+// to wait before the next attempt.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	errorClasses   []string
+	statusCodes    []int
+}
+
+func newRetryPolicy(cfg *Config) retryPolicy {
+	return retryPolicy{
+		maxAttempts:    max(cfg.RetryMaxAttempts, 1),
+		initialBackoff: cfg.RetryInitialBackoff,
+		maxBackoff:     max(cfg.RetryMaxBackoff, cfg.RetryInitialBackoff),
+		errorClasses:   cfg.RetryErrorClasses,
+		statusCodes:    cfg.RetryStatusCodes,
+	}
+}
+
+// retryable reports whether result, the outcome of the given attempt, is worth
+// another attempt.
+func (p retryPolicy) retryable(attempt int, result domain.LinkResult) bool {
+	if attempt >= p.maxAttempts {
+		return false
+	}
+
+	if result.Status != domain.StatusNotAvailable && result.Status != domain.StatusRateLimited {
+		return false
+	}
+
+	if result.StatusCode != 0 {
+		return slices.Contains(p.statusCodes, result.StatusCode)
+	}
+
+	return slices.Contains(p.errorClasses, result.ErrorClass)
+}
+
+// delay returns the time to wait after the given attempt. The exponential
+// backoff is jittered between half and its full value, so that links failing
+// together are not retried in lockstep. A longer Retry-After asked by the
+// server is honored, up to the maximum backoff.
+func (p retryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := p.initialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+
+	if half := backoff / 2; half > 0 {
+		backoff = half + rand.N(half+1)
+	}
+
+	if retryAfter > backoff {
+		backoff = min(retryAfter, p.maxBackoff)
+	}
+
+	return backoff
+}
+
+// parseRetryAfter parses the Retry-After header, given either in seconds or as
+// an HTTP date. It returns 0 if the header is missing or malformed.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+
+	return max(date.Sub(now), 0)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// userAgent is sent with every http(s) check and matched against the
+	// groups of robots.txt.
+	userAgent = "link-service"
+
+	robotsTTL     = time.Hour
+	robotsMaxSize = 512 << 10
+)
+
+var errDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsCache keeps the robots.txt rules of each scheme and host. Concurrent
+// checks of the same host wait for a single fetch.
+type robotsCache struct {
+	mu      *sync.Mutex
+	entries map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	ready   chan struct{}
+	rules   robotsRules
+	expires time.Time
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{
+		mu:      &sync.Mutex{},
+		entries: make(map[string]*robotsEntry),
+	}
+}
+
+// allowedByRobots reports whether the robots.txt of the host of link allows
+// checking it. Only http(s) links are subject to robots.txt, and only if the
+// service respects it. A robots.txt that cannot be fetched allows everything.
+func (s *Service) allowedByRobots(ctx context.Context, link string) bool {
+	if s.robots == nil {
+		return true
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return true
+	}
+
+	origin := u.Scheme + "://" + u.Host
+
+	s.robots.mu.Lock()
+	entry, ok := s.robots.entries[origin]
+	if ok && entry.expired(time.Now()) {
+		ok = false
+	}
+
+	if !ok {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		s.robots.entries[origin] = entry
+		s.robots.mu.Unlock()
+
+		entry.rules, entry.expires = s.fetchRobots(ctx, origin)
+		close(entry.ready)
+	} else {
+		s.robots.mu.Unlock()
+
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return true
+		}
+	}
+
+	return entry.rules.allows(u.RequestURI())
+}
+
+// expired reports whether the fetch of e is done and its rules are stale.
+func (e *robotsEntry) expired(now time.Time) bool {
+	select {
+	case <-e.ready:
+		return now.After(e.expires)
+	default:
+		return false
+	}
+}
+
+// fetchRobots fetches the robots.txt of origin. Rules are cached for
+// robotsTTL, failed fetches are attempted again on the next check.
+func (s *Service) fetchRobots(ctx context.Context, origin string) (robotsRules, time.Time) {
+	rules, err := s.getRobots(ctx, origin)
+	if err != nil {
+		s.logger.Debug("failed to fetch robots.txt", zap.String("origin", origin), zap.Error(err))
+		return nil, time.Now()
+	}
+
+	return rules, time.Now().Add(robotsTTL)
+}
+
+// getRobots fetches the robots.txt of origin through the host limiter, like
+// any other check of the host.
+func (s *Service) getRobots(ctx context.Context, origin string) (robotsRules, error) {
+	release, err := s.hosts.acquire(ctx, linkHost(origin))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(io.LimitReader(resp.Body, robotsMaxSize), userAgent), nil
+
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// A missing robots.txt allows everything.
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+// robotsRules are the Allow and Disallow lines that apply to the service.
+type robotsRules []robotsRule
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// parseRobots reads the rules of the groups naming the product token of
+// agent, the part before the first "/", or of the "*" group if there are none.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	product, _, _ := strings.Cut(agent, "/")
+
+	var (
+		own, wildcard robotsRules
+		matchOwn      bool
+		matchAny      bool
+		foundOwn      bool
+		inAgents      bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				matchOwn, matchAny = false, false
+				inAgents = true
+			}
+
+			if value == "*" {
+				matchAny = true
+			} else if strings.EqualFold(value, product) {
+				matchOwn = true
+				foundOwn = true
+			}
+
+		case "allow", "disallow":
+			inAgents = false
+
+			// An empty Disallow allows everything.
+			if value == "" {
+				continue
+			}
+
+			rule := robotsRule{pattern: value, allow: key == "allow"}
+			if matchOwn {
+				own = append(own, rule)
+			}
+			if matchAny {
+				wildcard = append(wildcard, rule)
+			}
+
+		default:
+			inAgents = false
+		}
+	}
+
+	if foundOwn {
+		return own
+	}
+
+	return wildcard
+}
+
+// allows reports whether path may be fetched. The longest matching pattern
+// wins, Allow wins ties.
+func (r robotsRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allowed := true
+	longest := -1
+
+	for _, rule := range r {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || len(rule.pattern) == longest && rule.allow {
+			allowed = rule.allow
+			longest = len(rule.pattern)
+		}
+	}
+
+	return allowed
+}
+
+// matchRobotsPattern matches path against a robots.txt path pattern, where *
+// stands for any sequence of characters and a trailing $ anchors the end.
+func matchRobotsPattern(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+
+	for _, part := range parts[1:] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+
+	if !anchored {
+		return true
+	}
+
+	// The last part must end the path, so it is matched at its last position.
+	last := parts[len(parts)-1]
+	return path == "" || len(parts) > 1 && strings.HasSuffix(path, last)
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSchedule = errors.New("invalid schedule")
+
+// cronHorizon bounds the search for the next run of a cron schedule, so that
+// schedules that never fire, such as February 30th, do not loop forever.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// cronGapWindow is the span over which the runs of a cron schedule are
+// compared with the minimum interval. A year covers every day, weekday and
+// month pattern.
+const cronGapWindow = 366 * 24 * time.Hour
+
+// schedule tells when a monitor runs next.
+type schedule interface {
+	// next returns the time of the first run after t, or the zero time if
+	// there is none.
+	next(t time.Time) time.Time
+}
+
+// intervalSchedule runs at a fixed interval from the previous run.
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule runs at the minutes matching all of its fields, in UTC. Each
+// field is a bit set of the values it matches.
+type cronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// anyDay and anyWeekday are set for fields given as "*". If both day
+	// fields are restricted, a day matching either of them is a match, as in
+	// cron.
+	anyDay     bool
+	anyWeekday bool
+}
+
+// cronField is the range of values of a cron field.
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// Sunday is either 0 or 7.
+	{name: "day of week", min: 0, max: 7},
+}
+
+// scheduleAliases are the shorthands for common cron expressions.
+var scheduleAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseSchedule parses spec, which is one of:
+//   - an interval, "@every 5m" or just "5m", no shorter than minInterval,
+//   - a cron expression "minute hour day-of-month month day-of-week", whose
+//     fields are "*", numbers, ranges "1-5" and lists "1,15", optionally with
+//     a step "*/10", and whose runs are no closer than minInterval,
+//   - one of @hourly, @daily, @weekly and @monthly.
+func parseSchedule(spec string, minInterval time.Duration) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if alias, ok := scheduleAliases[spec]; ok {
+		spec = alias
+	}
+
+	interval, isInterval := strings.CutPrefix(spec, "@every ")
+	if !isInterval && !strings.ContainsAny(spec, " \t") {
+		interval, isInterval = spec, true
+	}
+
+	if isInterval {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errInvalidSchedule, spec, err)
+		}
+
+		if d <= 0 || d < minInterval {
+			return nil, fmt.Errorf("%w: interval %s is shorter than %s", errInvalidSchedule, d, minInterval)
+		}
+
+		return intervalSchedule(d), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w: %q: cron expressions have %d fields", errInvalidSchedule, spec, len(cronFields))
+	}
+
+	var sets [len(cronFields)]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errInvalidSchedule, spec, err)
+		}
+
+		sets[i] = set
+	}
+
+	// Sunday is stored as 0 only.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	s := &cronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+
+	first := s.next(time.Now())
+	if first.IsZero() {
+		return nil, fmt.Errorf("%w: %q never runs", errInvalidSchedule, spec)
+	}
+
+	gap := s.shortestGap(first, first.Add(cronGapWindow), minInterval)
+	if gap < minInterval {
+		return nil, fmt.Errorf("%w: %q runs %s apart, which is shorter than %s", errInvalidSchedule, spec, gap, minInterval)
+	}
+
+	return s, nil
+}
+
+// shortestGap returns the shortest time between consecutive runs from start
+// until end, stopping at the first gap below limit. Cron runs are at least a
+// minute apart, so limits up to a minute need no search.
+func (s *cronSchedule) shortestGap(start time.Time, end time.Time, limit time.Duration) time.Duration {
+	shortest := end.Sub(start)
+	if limit <= time.Minute {
+		return shortest
+	}
+
+	for prev := start; prev.Before(end); {
+		t := s.next(prev)
+		if t.IsZero() {
+			break
+		}
+
+		shortest = min(shortest, t.Sub(prev))
+		if shortest < limit {
+			break
+		}
+
+		prev = t
+	}
+
+	return shortest
+}
+
+// parseCronField returns the set of values matched by field.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var set uint64
+
+	for item := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepStr, f.name)
+			}
+		}
+
+		from, to := f.min, f.max
+		switch {
+		case rng == "*":
+
+		case strings.Contains(rng, "-"):
+			fromStr, toStr, _ := strings.Cut(rng, "-")
+
+			var err error
+			from, err = parseCronValue(fromStr, f)
+			if err != nil {
+				return 0, err
+			}
+
+			to, err = parseCronValue(toStr, f)
+			if err != nil {
+				return 0, err
+			}
+
+			if from > to {
+				return 0, fmt.Errorf("invalid range %q in %s", rng, f.name)
+			}
+
+		default:
+			var err error
+			from, err = parseCronValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+
+			// A single value with a step, such as "5/15", runs from it
+			// up to the maximum.
+			if !hasStep {
+				to = from
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func parseCronValue(s string, f cronField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be %d-%d", f.name, s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronHorizon)
+
+	for t.Before(limit) {
+		if !has(s.months, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !has(s.hours, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !has(s.minutes, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	day := has(s.days, t.Day())
+	weekday := has(s.weekdays, int(t.Weekday()))
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
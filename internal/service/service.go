@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,61 +17,240 @@ import (
 	"link-service/internal/repository"
 )
 
-const (
-	statusAvailable    = "available"
-	statusNotAvailable = "not available"
-	statusUnknown      = "unknown"
-
-	httpsPrefix = "https://"
-	httpPrefix  = "http://"
+var (
+	ErrAppStopped  = errors.New("application is stopped")
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrJobNotFound = errors.New("job not found")
+	// ErrInvalidCallbackURL wraps the reason a callback URL is rejected.
+	ErrInvalidCallbackURL = errors.New("invalid callback url")
+
+	// ErrRequestTimeout is returned along with a partial record whose pending
+	// links are being checked in the background.
+	ErrRequestTimeout = errors.New("request timed out")
 )
 
-var (
-	ErrAppStopped = errors.New("application is stopped")
+const (
+	drainPollInterval = 50 * time.Millisecond
+	// defaultDrainGrace bounds the wait for aborted checks to save their
+	// results once the shutdown deadline is exceeded.
+	defaultDrainGrace = 5 * time.Second
 )
 
 type Config struct {
-	PingTimeout time.Duration `env:"SERVICE_PING_TIMEOUT" env-required:"true"`
+	PingTimeout    time.Duration `env:"SERVICE_PING_TIMEOUT" env-required:"true"`
+	Workers        int           `env:"SERVICE_WORKERS" env-default:"8"`
+	MaxConnections int           `env:"SERVICE_MAX_CONNECTIONS" env-default:"64"`
+	JobWorkers     int           `env:"SERVICE_JOB_WORKERS" env-default:"2"`
+	QueueSize      int           `env:"SERVICE_QUEUE_SIZE" env-default:"1000"`
+	// CompleteTimedOut makes links left unchecked by a timed out request be
+	// checked in the background instead of being saved as timed out.
+	CompleteTimedOut bool `env:"SERVICE_COMPLETE_TIMED_OUT" env-default:"true"`
+	// RetryMaxAttempts is the number of attempts made for a link, including the
+	// first one, before it is reported as not available.
+	RetryMaxAttempts    int           `env:"SERVICE_RETRY_MAX_ATTEMPTS" env-default:"3"`
+	RetryInitialBackoff time.Duration `env:"SERVICE_RETRY_INITIAL_BACKOFF" env-default:"200ms"`
+	RetryMaxBackoff     time.Duration `env:"SERVICE_RETRY_MAX_BACKOFF" env-default:"5s"`
+	// RetryErrorClasses and RetryStatusCodes list the failures worth retrying.
+	RetryErrorClasses []string `env:"SERVICE_RETRY_ERROR_CLASSES" env-default:"connect,timeout,reset"`
+	RetryStatusCodes  []int    `env:"SERVICE_RETRY_STATUS_CODES" env-default:"429,502,503,504"`
+	// AvailableStatuses, FollowRedirects and AuthAvailable define the default
+	// availability policy, see domain.Availability.
+	AvailableStatuses StatusRanges `env:"SERVICE_AVAILABLE_STATUSES" env-default:"200-299"`
+	FollowRedirects   bool         `env:"SERVICE_FOLLOW_REDIRECTS" env-default:"true"`
+	AuthAvailable     bool         `env:"SERVICE_AUTH_AVAILABLE" env-default:"false"`
+	// MaxRedirects is the number of redirects followed before a check fails.
+	MaxRedirects int `env:"SERVICE_MAX_REDIRECTS" env-default:"10"`
+	// CertExpiryWindow flags certificates of https links that expire within
+	// it. Zero disables the flag.
+	CertExpiryWindow time.Duration `env:"SERVICE_CERT_EXPIRY_WINDOW" env-default:"336h"`
+	// BlockPrivateNetworks keeps checks from connecting to loopback,
+	// link-local, private and other internal addresses. BlockedCIDRs are
+	// blocked in addition, AllowedCIDRs are allowed even if blocked.
+	BlockPrivateNetworks bool  `env:"SERVICE_BLOCK_PRIVATE_NETWORKS" env-default:"true"`
+	BlockedCIDRs         CIDRs `env:"SERVICE_BLOCKED_CIDRS"`
+	AllowedCIDRs         CIDRs `env:"SERVICE_ALLOWED_CIDRS"`
+	// HostRateLimit is the number of checks per second started against a
+	// single host, with bursts of up to HostBurst. HostMaxConnections bounds
+	// the checks running against a single host at once. Zero disables either
+	// limit.
+	HostRateLimit      float64 `env:"SERVICE_HOST_RATE_LIMIT" env-default:"5"`
+	HostBurst          int     `env:"SERVICE_HOST_BURST" env-default:"5"`
+	HostMaxConnections int     `env:"SERVICE_HOST_MAX_CONNECTIONS" env-default:"4"`
+	// RespectRobots skips http(s) links disallowed by the robots.txt of their
+	// host.
+	RespectRobots bool `env:"SERVICE_RESPECT_ROBOTS" env-default:"false"`
+	// BreakerFailures is the number of consecutive failures to reach a host
+	// that open its circuit, after which its links are not checked for
+	// BreakerCooldown. Zero disables the circuit breaker.
+	BreakerFailures int           `env:"SERVICE_BREAKER_FAILURES" env-default:"5"`
+	BreakerCooldown time.Duration `env:"SERVICE_BREAKER_COOLDOWN" env-default:"1m"`
+	// CacheTTL is how long link results are reused by later checks, at most
+	// CacheSize of them. Zero disables the cache.
+	CacheTTL  time.Duration `env:"SERVICE_CACHE_TTL" env-default:"30s"`
+	CacheSize int           `env:"SERVICE_CACHE_SIZE" env-default:"10000"`
+	// MonitorMinInterval is the shortest interval new monitors may run at.
+	MonitorMinInterval time.Duration `env:"SERVICE_MONITOR_MIN_INTERVAL" env-default:"1m"`
+	// MonitorHistory is the number of latest runs kept per monitor.
+	MonitorHistory int `env:"SERVICE_MONITOR_HISTORY" env-default:"100"`
+}
+
+// Notifier is told about every record saved by the service.
+type Notifier interface {
+	Notify(rec *domain.Record)
+}
+
+// Options are the per-request settings of a link check.
+type Options struct {
+	CallbackURL string
+	// Availability overrides the default availability policy if not nil.
+	Availability *domain.Availability
+	// NoCache makes every link be checked anew instead of reusing a cached
+	// result.
+	NoCache bool
 }
 
 type Service struct {
-	counter    int64
-	repository repository.Repository
-	httpClient *http.Client
-	logger     *zap.Logger
+	counter            int64
+	repository         repository.Repository
+	notifier           Notifier
+	httpClient         *http.Client
+	dialer             Dialer
+	resolver           Resolver
+	tlsConfig          *tls.Config
+	workers            int
+	connSem            chan struct{}
+	retry              retryPolicy
+	availability       domain.Availability
+	maxRedirects       int
+	certExpiryWindow   time.Duration
+	pingTimeout        time.Duration
+	checkers           map[string]Checker
+	guard              *addressGuard
+	hosts              *hostLimiter
+	robots             *robotsCache
+	breaker            *circuitBreaker
+	cache              *resultCache
+	jobWorkers         int
+	queueSize          int
+	queue              *jobQueue
+	completeTimedOut   bool
+	jobsMu             *sync.Mutex
+	jobs               map[int64]*job
+	monitors           *monitorSet
+	monitorMinInterval time.Duration
+	monitorHistory     int
+	inflight           atomic.Int64
+	drainCtx           context.Context
+	drain              context.CancelFunc
+	drainGrace         time.Duration
+	logger             *zap.Logger
+}
+
+type linkResult struct {
+	link   string
+	result domain.LinkResult
 }
 
-func New(repo repository.Repository, cfg *Config, logger *zap.Logger) *Service {
+func New(repo repository.Repository, notifier Notifier, cfg *Config, logger *zap.Logger, opts ...Option) *Service {
 	lastLinksNum := repo.LoadLastLinksNum()
 
-	return &Service{
-		repository: repo,
-		counter:    lastLinksNum,
-		httpClient: &http.Client{
-			Timeout: cfg.PingTimeout,
-		},
-		logger: logger,
+	workers := max(cfg.Workers, 1)
+	maxConnections := max(cfg.MaxConnections, 1)
+	drainCtx, drain := context.WithCancel(context.Background())
+
+	availability := domain.Availability{
+		Statuses:        cfg.AvailableStatuses,
+		FollowRedirects: cfg.FollowRedirects,
+		AuthAvailable:   cfg.AuthAvailable,
+	}
+	if len(availability.Statuses) == 0 {
+		availability.Statuses = defaultStatuses
+	}
+
+	maxRedirects := cfg.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	guard := &addressGuard{
+		blockPrivate: cfg.BlockPrivateNetworks,
+		blocked:      cfg.BlockedCIDRs,
+		allowed:      cfg.AllowedCIDRs,
 	}
+
+	s := &Service{
+		repository:         repo,
+		notifier:           notifier,
+		counter:            lastLinksNum,
+		dialer:             &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolver:           net.DefaultResolver,
+		workers:            workers,
+		connSem:            make(chan struct{}, maxConnections),
+		retry:              newRetryPolicy(cfg),
+		availability:       availability,
+		maxRedirects:       maxRedirects,
+		certExpiryWindow:   cfg.CertExpiryWindow,
+		pingTimeout:        cfg.PingTimeout,
+		guard:              guard,
+		hosts:              newHostLimiter(cfg),
+		breaker:            newCircuitBreaker(cfg),
+		jobWorkers:         max(cfg.JobWorkers, 1),
+		queueSize:          cfg.QueueSize,
+		queue:              newJobQueue(),
+		completeTimedOut:   cfg.CompleteTimedOut,
+		jobsMu:             &sync.Mutex{},
+		jobs:               make(map[int64]*job),
+		monitors:           newMonitorSet(),
+		monitorMinInterval: cfg.MonitorMinInterval,
+		monitorHistory:     max(cfg.MonitorHistory, 1),
+		drainCtx:           drainCtx,
+		drain:              drain,
+		drainGrace:         defaultDrainGrace,
+		logger:             logger,
+	}
+
+	if cfg.RespectRobots {
+		s.robots = newRobotsCache()
+	}
+
+	if cfg.CacheTTL > 0 {
+		s.cache = newResultCache(cfg)
+	}
+
+	s.checkers = s.defaultCheckers()
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.httpClient = &http.Client{
+		Transport:     s.newTransport(),
+		CheckRedirect: checkRedirect,
+		Timeout:       cfg.PingTimeout,
+	}
+
+	return s
 }
 
-func (s *Service) Process(serverCtx context.Context, requestCtx context.Context, links []string) (*domain.Record, error) {
-	s.incCounter()
-	rec := &domain.Record{
-		Links: make(map[string]string),
-		ID:    s.counter,
+// Process checks links and saves them as a record, in the order given. Links
+// are checked in their normalized form, so the same link spelled differently
+// is checked once.
+func (s *Service) Process(serverCtx context.Context, requestCtx context.Context, links []string, opts Options) (*domain.Record, error) {
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
+	id, err := s.nextID()
+	if err != nil {
+		return nil, err
 	}
 
+	entries := s.newEntries(links)
+
 	select {
 	case <-serverCtx.Done():
-		for _, link := range links {
-			rec.Links[link] = statusUnknown
-		}
-
-		err := s.repository.SaveTempRecord(rec)
+		rec, err := s.saveTempRecord(id, entries, nil, opts)
 		if err != nil {
-			s.decCounter()
-			s.logger.Error("failed to save temp record", zap.Error(err))
-			return nil, fmt.Errorf("failed to save temp record: %w", err)
+			return nil, err
 		}
 
 		return rec, ErrAppStopped
@@ -77,36 +258,80 @@ func (s *Service) Process(serverCtx context.Context, requestCtx context.Context,
 	default:
 	}
 
-	for _, link := range links {
-		select {
-		case <-requestCtx.Done():
-			s.decCounter()
-			s.logger.Info(requestCtx.Err().Error(), zap.String("link", link))
-			return nil, requestCtx.Err()
+	ctx, cancel := context.WithCancel(requestCtx)
+	defer cancel()
 
-		default:
-		}
+	stop := context.AfterFunc(s.drainCtx, cancel)
+	defer stop()
+
+	results, err := s.checkLinks(ctx, checkedLinks(entries), opts, nil)
+	if err != nil {
+		if s.drainCtx.Err() != nil {
+			rec, err := s.saveTempRecord(id, entries, results, opts)
+			if err != nil {
+				return nil, err
+			}
 
-		statusCode, err := s.ping(link)
-		if err != nil || statusCode != http.StatusOK {
-			s.logger.Warn("failed to ping link", zap.String("link", link), zap.Error(err))
-			rec.Links[link] = statusNotAvailable
-		} else {
-			rec.Links[link] = statusAvailable
+			return rec, ErrAppStopped
 		}
+
+		s.logger.Info("request timed out, saving partial results", zap.Int64("id", id), zap.Error(err))
+		return s.saveTimedOut(id, entries, results, opts)
 	}
 
-	err := s.repository.SaveRecord(rec)
+	rec := newRecord(id, withResults(entries, results, domain.LinkResult{Status: domain.StatusUnknown}), opts)
+
+	err = s.repository.SaveRecord(rec)
 	if err != nil {
-		s.decCounter()
 		s.logger.Error("failed to save record", zap.Error(err))
 		return nil, fmt.Errorf("failed to save record: %w", err)
 	}
 
+	s.notify(rec)
+
 	s.logger.Info("success process record")
 	return rec, nil
 }
 
+// saveTimedOut keeps the results of a request that timed out. The links left
+// unchecked are either queued for a background check, in which case the record
+// is returned with pending statuses along with ErrRequestTimeout, or saved
+// right away with the timeout status.
+func (s *Service) saveTimedOut(id int64, entries []domain.LinkEntry, results map[string]domain.LinkResult, opts Options) (*domain.Record, error) {
+	if s.completeTimedOut {
+		rec, err := s.saveTempRecord(id, entries, results, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		j := newJob(id, entries, opts)
+		for link, result := range results {
+			j.setResult(link, result)
+		}
+
+		s.enqueue(j)
+
+		pending := *rec
+		pending.Links = withResults(entries, results, domain.LinkResult{Status: domain.StatusPending})
+
+		return &pending, ErrRequestTimeout
+	}
+
+	rec := newRecord(id, withResults(entries, results, domain.LinkResult{Status: domain.StatusTimeout}), opts)
+
+	err := s.repository.SaveRecord(rec)
+	if err != nil {
+		s.logger.Error("failed to save timed out record", zap.Int64("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to save record: %w", err)
+	}
+
+	s.notify(rec)
+
+	return rec, nil
+}
+
+// ProcessTempRecords puts the jobs left over from the previous run back into
+// the queue. They are picked up once RunJobs is started.
 func (s *Service) ProcessTempRecords() error {
 	records, err := s.repository.LoadTempRecords()
 	if err != nil {
@@ -114,61 +339,376 @@ func (s *Service) ProcessTempRecords() error {
 		return fmt.Errorf("failed to load temp records: %w", err)
 	}
 
+	// Temp records keep the links_num that was already handed out to the
+	// client, so the counter must never fall behind them.
 	for _, tempRec := range records {
-		s.incCounter()
-		rec := &domain.Record{
-			ID:    s.counter,
-			Links: make(map[string]string),
-		}
-
-		for link := range tempRec.Links {
-			statusCode, err := s.ping(link)
-			if err != nil || statusCode != http.StatusOK {
-				rec.Links[link] = statusNotAvailable
-			} else {
-				rec.Links[link] = statusAvailable
+		s.raiseCounter(tempRec.ID)
+	}
+
+	for _, tempRec := range records {
+		_, err = s.repository.GetRecord(tempRec.ID)
+		if err == nil {
+			s.logger.Warn("temp record already processed", zap.Int64("id", tempRec.ID))
+
+			err = s.repository.DeleteTempRecord(tempRec.ID)
+			if err != nil {
+				s.logger.Error("failed to delete temp record", zap.Int64("id", tempRec.ID), zap.Error(err))
 			}
+
+			continue
 		}
 
-		err = s.repository.SaveRecord(rec)
-		if err != nil {
-			s.logger.Error("failed to save processed temp record", zap.Int64("id", rec.ID), zap.Error(err))
+		if !errors.Is(err, repository.ErrRecordNotFound) {
+			s.logger.Error("failed to check temp record", zap.Int64("id", tempRec.ID), zap.Error(err))
 			continue
 		}
+
+		j := newJob(tempRec.ID, tempRec.Links, recordOptions(&tempRec))
+
+		// Results checked before an interrupted shutdown are kept.
+		for _, entry := range tempRec.Links {
+			if entry.Result.Status != domain.StatusUnknown {
+				j.setResult(entry.Normalized, entry.Result)
+			}
+		}
+
+		s.enqueue(j)
 	}
 
-	err = s.repository.ClearTempFile()
-	if err != nil {
-		s.logger.Error("failed to clear temp file", zap.Error(err))
-		return fmt.Errorf("failed to clear temp file: %w", err)
+	s.logger.Info("successfully queued temp records", zap.Int("count", len(records)))
+	return nil
+}
+
+// Shutdown waits for in-flight checks to finish. If ctx is done first, the
+// remaining checks are aborted and saved as temp records with the results
+// gathered so far, to be completed by ProcessTempRecords on the next start.
+// Checks that do not stop within a grace period of that are given up on.
+func (s *Service) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.inflight.Load() > 0 {
+		select {
+		case <-ticker.C:
+
+		case <-ctx.Done():
+			s.logger.Warn("shutdown deadline exceeded, saving unfinished checks", zap.Int64("in_flight", s.inflight.Load()))
+			s.drain()
+
+			// Aborted checks only have to save their temp records, but a
+			// checker that ignores cancellation must not hang the shutdown.
+			grace := time.NewTimer(s.drainGrace)
+			defer grace.Stop()
+
+			for s.inflight.Load() > 0 {
+				select {
+				case <-ticker.C:
+				case <-grace.C:
+					s.logger.Error("checks did not stop after drain, giving up", zap.Int64("in_flight", s.inflight.Load()))
+					return ctx.Err()
+				}
+			}
+
+			return ctx.Err()
+		}
 	}
 
-	s.logger.Info("successfully processed temp records")
+	s.drain()
 	return nil
 }
 
-func (s *Service) ping(link string) (int, error) {
-	if !strings.HasPrefix(link, httpPrefix) && !strings.HasPrefix(link, httpsPrefix) {
-		link = httpsPrefix + link
+// checkLinks checks links with opts using at most s.workers goroutines.
+// Outbound connections are additionally bounded across all requests by
+// s.connSem.
+// onResult, if not nil, is called as soon as each link has been checked.
+// If ctx is done before all links are checked, the results gathered so far
+// are returned along with ctx.Err().
+func (s *Service) checkLinks(ctx context.Context, links []string, opts Options, onResult func(link string, result domain.LinkResult)) (map[string]domain.LinkResult, error) {
+	policy := s.policy(opts)
+
+	jobs := make(chan string)
+	results := make(chan linkResult, len(links))
+
+	var wg sync.WaitGroup
+	for range min(s.workers, len(links)) {
+		wg.Go(func() {
+			for link := range jobs {
+				results <- linkResult{link: link, result: s.checkCached(ctx, link, policy, opts.NoCache)}
+			}
+		})
+	}
+
+	go func() {
+		defer close(jobs)
+
+		for _, link := range links {
+			select {
+			case jobs <- link:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	checked := make(map[string]domain.LinkResult, len(links))
+	for res := range results {
+		// Checks aborted by ctx do not count as results.
+		if res.result.Status == domain.StatusUnknown {
+			continue
+		}
+
+		checked[res.link] = res.result
+
+		if onResult != nil {
+			onResult(res.link, res.result)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return checked, err
+	}
+
+	return checked, nil
+}
+
+// check checks link, attempting it again on failures allowed by the retry
+// policy. The connection slot is not held while waiting between attempts.
+func (s *Service) check(ctx context.Context, link string, policy domain.Availability) domain.LinkResult {
+	for attempt := 1; ; attempt++ {
+		result, retryAfter := s.checkOnce(ctx, link, policy)
+		if result.Status == domain.StatusUnknown {
+			return result
+		}
+
+		result.Attempts = attempt
+
+		if !s.retry.retryable(attempt, result) {
+			return result
+		}
+
+		delay := s.retry.delay(attempt, retryAfter)
+		s.logger.Info("retrying link", zap.String("link", link), zap.Int("attempt", attempt), zap.Duration("delay", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		}
 	}
+}
+
+// checkOnce makes a single attempt to check link. It also returns the delay
+// asked by the server through Retry-After, if any. The attempt waits for the
+// host limiter first, so that links of a slow host do not hold connection
+// slots needed by others. Links of hosts with an open circuit are not checked
+// at all.
+func (s *Service) checkOnce(ctx context.Context, link string, policy domain.Availability) (result domain.LinkResult, retryAfter time.Duration) {
+	checker, target, err := s.checker(link)
+	host := linkHost(target)
+
+	if !s.breaker.allow(host, time.Now()) {
+		retryAt := s.breaker.retryAt(host).UTC()
+
+		return domain.LinkResult{
+			Status:    domain.StatusCircuitOpen,
+			Error:     fmt.Sprintf("%v for %s until %s", errCircuitOpen, host, retryAt.Format(time.RFC3339)),
+			CheckedAt: time.Now().UTC(),
+		}, 0
+	}
+	defer func() { s.recordOutcome(host, result, err) }()
+
+	// robots.txt is fetched before the check takes its own place in the
+	// host limiter.
+	if err == nil && !s.allowedByRobots(ctx, target) {
+		err = errDisallowed
+		s.logger.Info("link is disallowed by robots.txt", zap.String("link", link))
+
+		return domain.LinkResult{
+			Status:    domain.StatusDisallowed,
+			Error:     err.Error(),
+			CheckedAt: time.Now().UTC(),
+		}, 0
+	}
+
+	release, acquireErr := s.hosts.acquire(ctx, host)
+	if acquireErr != nil {
+		return domain.LinkResult{Status: domain.StatusUnknown}, 0
+	}
+	defer release()
+
+	select {
+	case s.connSem <- struct{}{}:
+	case <-ctx.Done():
+		return domain.LinkResult{Status: domain.StatusUnknown}, 0
+	}
+	defer func() { <-s.connSem }()
 
-	resp, err := s.httpClient.Head(link)
 	if err == nil {
-		return resp.StatusCode, nil
+		result, err = checker.Check(ctx, target, policy)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return domain.LinkResult{Status: domain.StatusUnknown}, 0
+	}
+
+	if errors.Is(err, errBlocked) {
+		s.logger.Warn("link is blocked", zap.String("link", link), zap.Error(err))
+		result.Status = domain.StatusBlocked
+		result.Error = err.Error()
+		return result, 0
 	}
 
-	resp, err = s.httpClient.Get(link)
 	if err != nil {
-		return 0, fmt.Errorf("failed to ping link: %w", err)
+		s.logger.Warn("link is not available", zap.String("link", link), zap.Error(err))
+		result.Status = domain.StatusNotAvailable
+		result.ErrorClass = classifyError(err)
+		result.Error = err.Error()
+
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			// The host asks to slow down, so all of its links wait.
+			if statusErr.statusCode == http.StatusTooManyRequests {
+				result.Status = domain.StatusRateLimited
+				s.hosts.backoff(host, statusErr.retryAfter)
+			}
+
+			return result, statusErr.retryAfter
+		}
+
+		return result, 0
 	}
 
-	return resp.StatusCode, nil
+	result.Status = domain.StatusAvailable
+	if len(result.Redirects) > 0 {
+		result.Status = domain.StatusRedirected
+	}
+
+	return result, 0
+}
+
+// recordOutcome reports the outcome of a check of host to the circuit
+// breaker. Only unreachable hosts count as failures and only hosts that
+// answered count as successes, other outcomes say nothing about the host.
+func (s *Service) recordOutcome(host string, result domain.LinkResult, err error) {
+	switch {
+	case hostFailed(result) && !errors.Is(err, errDNSMismatch):
+		s.breaker.failure(host, time.Now())
+	case err == nil && result.Status != domain.StatusUnknown, result.StatusCode != 0:
+		s.breaker.success(host)
+	default:
+		s.breaker.cancel(host)
+	}
+}
+
+// newEntries returns the entries of the submitted links, without results.
+// Links that cannot be normalized are checked as submitted, and fail as
+// invalid.
+func (s *Service) newEntries(links []string) []domain.LinkEntry {
+	entries := make([]domain.LinkEntry, len(links))
+	for i, link := range links {
+		normalized, err := s.NormalizeLink(link)
+		if err != nil {
+			normalized = link
+		}
+
+		entries[i] = domain.LinkEntry{Index: i, Link: link, Normalized: normalized}
+	}
+
+	return entries
 }
 
-func (s *Service) incCounter() {
-	atomic.AddInt64(&s.counter, 1)
+// checkedLinks returns the distinct links to check for entries, in order.
+func checkedLinks(entries []domain.LinkEntry) []string {
+	links := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		if !seen[entry.Normalized] {
+			seen[entry.Normalized] = true
+			links = append(links, entry.Normalized)
+		}
+	}
+
+	return links
 }
 
-func (s *Service) decCounter() {
-	atomic.AddInt64(&s.counter, -1)
+// withResults returns a copy of entries with the results of their links.
+// Entries of links missing from results get the missing result.
+func withResults(entries []domain.LinkEntry, results map[string]domain.LinkResult, missing domain.LinkResult) []domain.LinkEntry {
+	links := make([]domain.LinkEntry, len(entries))
+	for i, entry := range entries {
+		result, ok := results[entry.Normalized]
+		if !ok {
+			result = missing
+		}
+
+		entry.Result = result
+		links[i] = entry
+	}
+
+	return links
+}
+
+// newRecord builds the record saved under id for a check made with opts.
+func newRecord(id int64, links []domain.LinkEntry, opts Options) *domain.Record {
+	return &domain.Record{
+		Links:        links,
+		ID:           id,
+		CallbackURL:  opts.CallbackURL,
+		Availability: opts.Availability,
+		Timing:       domain.AggregateTimings(links),
+	}
+}
+
+// recordOptions restores the options a temp record was saved with.
+func recordOptions(rec *domain.Record) Options {
+	return Options{
+		CallbackURL:  rec.CallbackURL,
+		Availability: rec.Availability,
+	}
+}
+
+func (s *Service) notify(rec *domain.Record) {
+	if s.notifier != nil {
+		s.notifier.Notify(rec)
+	}
+}
+
+// nextID allocates the links_num of a new record, either from the repository
+// if it is an IDAllocator or from the in-memory counter. IDs are never given
+// back: a request that fails to save its record leaves a gap, as concurrent
+// requests may already hold the following IDs.
+func (s *Service) nextID() (int64, error) {
+	if allocator, ok := s.repository.(repository.IDAllocator); ok {
+		id, err := allocator.NextLinksNum()
+		if err != nil {
+			s.logger.Error("failed to allocate links num", zap.Error(err))
+			return 0, fmt.Errorf("failed to allocate links num: %w", err)
+		}
+
+		return id, nil
+	}
+
+	return s.incCounter(), nil
+}
+
+func (s *Service) incCounter() int64 {
+	return atomic.AddInt64(&s.counter, 1)
+}
+
+// raiseCounter moves the counter up to id if it is currently lower.
+func (s *Service) raiseCounter(id int64) {
+	for {
+		current := atomic.LoadInt64(&s.counter)
+		if current >= id || atomic.CompareAndSwapInt64(&s.counter, current, id) {
+			return
+		}
+	}
 }
@@ -2,6 +2,25 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -9,97 +28,1576 @@ import (
 	"go.uber.org/zap"
 
 	"link-service/internal/domain"
-	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/memory"
 )
 
 func TestProcess(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
 	tests := []struct {
-		name       string
-		serverCtx  context.Context
-		requestCtx context.Context
-		links      []string
-		wantRec    *domain.Record
-		wantErr    error
+		name        string
+		serverCtx   context.Context
+		requestCtx  context.Context
+		pingTimeout time.Duration
+		links       []string
+		wantRec     *recordSummary
+		wantErr     error
 	}{
 		{
-			name:       "success",
+			name:       "available",
 			serverCtx:  context.Background(),
 			requestCtx: context.Background(),
 			links: []string{
-				"google.com",
-				"yandex.ru",
+				"http://up.test",
+				"example.com",
 			},
-			wantRec: &domain.Record{
-				Links: map[string]string{
-					"google.com": statusAvailable,
-					"yandex.ru":  statusAvailable,
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://up.test": {Status: domain.StatusAvailable, StatusCode: http.StatusOK, FinalURL: "http://up.test/"},
+					"example.com":    {Status: domain.StatusAvailable, StatusCode: http.StatusOK, FinalURL: "https://example.com/"},
 				},
 				ID: 1,
 			},
-			wantErr: nil,
 		},
 		{
 			name:       "not available",
 			serverCtx:  context.Background(),
 			requestCtx: context.Background(),
 			links: []string{
-				"12dqf4wgf4.com",
-				"yandex.ru",
+				"http://down.test",
+				"http://missing.test",
 			},
-			wantRec: &domain.Record{
-				Links: map[string]string{
-					"12dqf4wgf4.com": statusNotAvailable,
-					"yandex.ru":      statusAvailable,
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://down.test":    {Status: domain.StatusNotAvailable, StatusCode: http.StatusInternalServerError, FinalURL: "http://down.test/", ErrorClass: domain.ErrorClassHTTP},
+					"http://missing.test": {Status: domain.StatusNotAvailable, ErrorClass: domain.ErrorClassDNS},
 				},
 				ID: 1,
 			},
-			wantErr: nil,
 		},
 		{
-			name: "app stoped",
-			serverCtx: func() context.Context {
-				canceledCtx, cancel := context.WithCancel(context.Background())
-				cancel()
-				return canceledCtx
-			}(),
+			name:       "redirect",
+			serverCtx:  context.Background(),
 			requestCtx: context.Background(),
 			links: []string{
-				"google.com",
-				"yandex.ru",
+				"http://moved.test",
+			},
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://moved.test": {Status: domain.StatusRedirected, StatusCode: http.StatusOK, FinalURL: "http://up.test/", Redirects: []domain.Redirect{{URL: "http://moved.test/", StatusCode: http.StatusFound, Location: "http://up.test/"}}},
+				},
+				ID: 1,
+			},
+		},
+		{
+			name:        "timeout",
+			serverCtx:   context.Background(),
+			requestCtx:  context.Background(),
+			pingTimeout: 100 * time.Millisecond,
+			links: []string{
+				"http://slow.test",
 			},
-			wantRec: &domain.Record{
-				Links: map[string]string{
-					"google.com": statusUnknown,
-					"yandex.ru":  statusUnknown,
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://slow.test": {Status: domain.StatusNotAvailable, ErrorClass: domain.ErrorClassTimeout},
+				},
+				ID: 1,
+			},
+		},
+		{
+			name:       "app stoped",
+			serverCtx:  canceledCtx,
+			requestCtx: context.Background(),
+			links: []string{
+				"http://up.test",
+				"http://down.test",
+			},
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://up.test":   {Status: domain.StatusUnknown},
+					"http://down.test": {Status: domain.StatusUnknown},
 				},
 				ID: 1,
 			},
 			wantErr: ErrAppStopped,
 		},
 		{
-			name:      "request context canceled",
-			serverCtx: context.Background(),
-			requestCtx: func() context.Context {
-				canceledCtx, cancel := context.WithCancel(context.Background())
-				cancel()
-				return canceledCtx
-			}(),
+			name:       "request context canceled",
+			serverCtx:  context.Background(),
+			requestCtx: canceledCtx,
 			links: []string{
-				"google.com",
-				"yandex.ru",
+				"http://up.test",
+				"http://down.test",
 			},
-			wantRec: nil,
-			wantErr: context.Canceled,
+			wantRec: &recordSummary{
+				Links: map[string]domain.LinkResult{
+					"http://up.test":   {Status: domain.StatusPending},
+					"http://down.test": {Status: domain.StatusPending},
+				},
+				ID: 1,
+			},
+			wantErr: ErrRequestTimeout,
 		},
 	}
 
+	network := newTestNetwork(t)
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			srv := New(filesystem.NewMockStorage(), 30*time.Second, zap.NewNop())
+			pingTimeout := tt.pingTimeout
+			if pingTimeout == 0 {
+				pingTimeout = 5 * time.Second
+			}
+
+			cfg := &Config{PingTimeout: pingTimeout, Workers: 4, MaxConnections: 8, CompleteTimedOut: true, FollowRedirects: true}
+			srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
 
-			gotRec, err := srv.Process(tt.serverCtx, tt.requestCtx, tt.links)
+			gotRec, err := srv.Process(tt.serverCtx, tt.requestCtx, tt.links, Options{})
 			assert.Equal(t, tt.wantErr, err)
-			assert.Equal(t, tt.wantRec, gotRec)
+			assert.Equal(t, tt.wantRec, summary(gotRec))
+		})
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{PingTimeout: 5 * time.Second, Workers: 4, MaxConnections: 8, FollowRedirects: true}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	links := []string{"http://empty.test", "http://private.test", "http://moved.test"}
+
+	tests := []struct {
+		name         string
+		availability *domain.Availability
+		want         map[string]domain.LinkResult
+	}{
+		{
+			name: "default",
+			want: map[string]domain.LinkResult{
+				"http://empty.test":   {Status: domain.StatusAvailable, StatusCode: http.StatusNoContent, FinalURL: "http://empty.test/"},
+				"http://private.test": {Status: domain.StatusNotAvailable, StatusCode: http.StatusUnauthorized, FinalURL: "http://private.test/", ErrorClass: domain.ErrorClassHTTP},
+				"http://moved.test":   {Status: domain.StatusRedirected, StatusCode: http.StatusOK, FinalURL: "http://up.test/", Redirects: []domain.Redirect{{URL: "http://moved.test/", StatusCode: http.StatusFound, Location: "http://up.test/"}}},
+			},
+		},
+		{
+			name: "auth available",
+			availability: &domain.Availability{
+				Statuses:        []domain.StatusRange{{From: 200, To: 200}},
+				FollowRedirects: true,
+				AuthAvailable:   true,
+			},
+			want: map[string]domain.LinkResult{
+				"http://empty.test":   {Status: domain.StatusNotAvailable, StatusCode: http.StatusNoContent, FinalURL: "http://empty.test/", ErrorClass: domain.ErrorClassHTTP},
+				"http://private.test": {Status: domain.StatusAvailable, StatusCode: http.StatusUnauthorized, FinalURL: "http://private.test/"},
+				"http://moved.test":   {Status: domain.StatusRedirected, StatusCode: http.StatusOK, FinalURL: "http://up.test/", Redirects: []domain.Redirect{{URL: "http://moved.test/", StatusCode: http.StatusFound, Location: "http://up.test/"}}},
+			},
+		},
+		{
+			name: "redirects not followed",
+			availability: &domain.Availability{
+				Statuses: []domain.StatusRange{{From: 200, To: 399}},
+			},
+			want: map[string]domain.LinkResult{
+				"http://empty.test":   {Status: domain.StatusAvailable, StatusCode: http.StatusNoContent, FinalURL: "http://empty.test/"},
+				"http://private.test": {Status: domain.StatusNotAvailable, StatusCode: http.StatusUnauthorized, FinalURL: "http://private.test/", ErrorClass: domain.ErrorClassHTTP},
+				"http://moved.test":   {Status: domain.StatusRedirected, StatusCode: http.StatusFound, FinalURL: "http://moved.test/", Redirects: []domain.Redirect{{URL: "http://moved.test/", StatusCode: http.StatusFound, Location: "http://up.test/"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := srv.Process(context.Background(), context.Background(), links, Options{Availability: tt.availability})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, summary(rec).Links)
+			assert.Equal(t, tt.availability, rec.Availability)
+		})
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{PingTimeout: 5 * time.Second, Workers: 4, MaxConnections: 8, FollowRedirects: true, MaxRedirects: 2}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	links := []string{"http://far.test/2", "http://far.test/3", "http://loop.test/"}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, domain.LinkResult{
+		Status:     domain.StatusRedirected,
+		StatusCode: http.StatusOK,
+		FinalURL:   "http://far.test/0",
+		Redirects: []domain.Redirect{
+			{URL: "http://far.test/2", StatusCode: http.StatusMovedPermanently, Location: "/1"},
+			{URL: "http://far.test/1", StatusCode: http.StatusMovedPermanently, Location: "/0"},
+		},
+	}, summary(rec).Links["http://far.test/2"])
+
+	tooFar := results(rec)["http://far.test/3"]
+	assert.Equal(t, domain.StatusNotAvailable, tooFar.Status)
+	assert.Equal(t, domain.ErrorClassRedirect, tooFar.ErrorClass)
+	assert.Len(t, tooFar.Redirects, 3)
+
+	loop := results(rec)["http://loop.test/"]
+	assert.Equal(t, domain.StatusNotAvailable, loop.Status)
+	assert.Equal(t, domain.ErrorClassRedirect, loop.ErrorClass)
+	assert.Contains(t, loop.Error, errRedirectLoop.Error())
+	assert.Equal(t, []domain.Redirect{{URL: "http://loop.test/", StatusCode: http.StatusFound, Location: "/"}}, loop.Redirects)
+}
+
+func TestCertificates(t *testing.T) {
+	network := newTestNetwork(t)
+	leaf := network.tlsServer.Certificate()
+
+	tests := []struct {
+		name            string
+		link            string
+		expiryWindow    time.Duration
+		wantStatus      string
+		wantMismatch    bool
+		wantVerifyError bool
+		wantExpiring    bool
+		wantErrorClass  string
+	}{
+		{
+			name:       "valid",
+			link:       "example.com",
+			wantStatus: domain.StatusAvailable,
+		},
+		{
+			name:         "expiring",
+			link:         "example.com",
+			expiryWindow: time.Until(leaf.NotAfter) + time.Hour,
+			wantStatus:   domain.StatusAvailable,
+			wantExpiring: true,
+		},
+		{
+			name:            "hostname mismatch",
+			link:            "wrong.test",
+			wantStatus:      domain.StatusNotAvailable,
+			wantMismatch:    true,
+			wantVerifyError: true,
+			wantErrorClass:  domain.ErrorClassTLS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{PingTimeout: 5 * time.Second, Workers: 1, MaxConnections: 1, CertExpiryWindow: tt.expiryWindow}
+			srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+			rec, err := srv.Process(context.Background(), context.Background(), []string{tt.link}, Options{})
+			assert.NoError(t, err)
+
+			result := results(rec)[tt.link]
+			assert.Equal(t, tt.wantStatus, result.Status)
+			assert.Equal(t, tt.wantErrorClass, result.ErrorClass)
+
+			if !assert.NotNil(t, result.TLS) {
+				return
+			}
+
+			assert.Equal(t, []domain.Certificate{{
+				Subject:  leaf.Subject.String(),
+				Issuer:   leaf.Issuer.String(),
+				SANs:     []string{"example.com", "*.example.com", "127.0.0.1", "::1"},
+				NotAfter: leaf.NotAfter.UTC(),
+			}}, result.TLS.Chain)
+			assert.Equal(t, int(math.Floor(time.Until(leaf.NotAfter).Hours()/24)), result.TLS.DaysToExpiry)
+			assert.Equal(t, tt.wantMismatch, result.TLS.HostnameMismatch)
+			assert.Equal(t, tt.wantVerifyError, result.TLS.VerifyError != "")
+			assert.Equal(t, tt.wantExpiring, result.TLS.Expiring)
+		})
+	}
+}
+
+func TestExpiredCertificate(t *testing.T) {
+	network := newTestNetwork(t)
+	leaf := network.tlsServer.Certificate()
+
+	srv := New(memory.New(zap.NewNop()), nil, &Config{CertExpiryWindow: 24 * time.Hour}, zap.NewNop())
+
+	info := srv.inspectCertificates([]*x509.Certificate{leaf}, "example.com", leaf.NotAfter.Add(36*time.Hour))
+	if !assert.NotNil(t, info) {
+		return
+	}
+
+	assert.Equal(t, -2, info.DaysToExpiry)
+	assert.True(t, info.Expiring)
+}
+
+func TestTiming(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{PingTimeout: 5 * time.Second, Workers: 2, MaxConnections: 2}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	rec, err := srv.Process(context.Background(), context.Background(), []string{"http://lazy.test", "example.com"}, Options{})
+	assert.NoError(t, err)
+
+	for link, result := range results(rec) {
+		if !assert.NotNil(t, result.Timing, link) {
+			return
+		}
+
+		assert.GreaterOrEqual(t, result.Timing.TotalMs, result.Timing.TTFBMs, link)
+		assert.GreaterOrEqual(t, result.Timing.TTFBMs, result.Timing.DNSMs+result.Timing.ConnectMs+result.Timing.TLSMs, link)
+	}
+
+	assert.GreaterOrEqual(t, results(rec)["http://lazy.test"].Timing.TTFBMs, int64(50))
+
+	if assert.NotNil(t, rec.Timing) {
+		assert.Equal(t, 2, rec.Timing.Links)
+		assert.Equal(t, "http://lazy.test/", rec.Timing.Slowest)
+		assert.Equal(t, results(rec)["http://lazy.test"].Timing.TotalMs, rec.Timing.Max.TotalMs)
+	}
+}
+
+func TestTimingWithoutTrace(t *testing.T) {
+	tm := timingFromContext(context.Background())
+	assert.Nil(t, tm)
+
+	assert.NotPanics(t, func() {
+		tm.addDNS(time.Millisecond)
+		tm.addConnect(time.Millisecond)
+		tm.addTLS(time.Millisecond)
+	}, "phases of checks that are not timed must be ignored")
+}
+
+func TestCheckers(t *testing.T) {
+	network := newTestNetwork(t)
+
+	echo := CheckerFunc(func(_ context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+		if strings.HasSuffix(link, "/down") {
+			return domain.LinkResult{Method: "echo"}, errors.New("echo failed")
+		}
+
+		return domain.LinkResult{Method: "echo", FinalURL: link}, nil
+	})
+
+	cfg := &Config{PingTimeout: 5 * time.Second, Workers: 4, MaxConnections: 8}
+	opts := append(network.options(), WithChecker("echo", echo))
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), opts...)
+
+	tests := []struct {
+		link           string
+		wantStatus     string
+		wantErrorClass string
+		wantAddresses  []string
+	}{
+		{link: "tcp://up.test:80", wantStatus: domain.StatusAvailable},
+		{link: "tcp://up.test:25", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassConnect},
+		{link: "tcp://up.test", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassInvalid},
+		{link: "dns://up.test", wantStatus: domain.StatusAvailable, wantAddresses: []string{"192.0.2.1"}},
+		{link: "dns://up.test?expect=192.0.2.1", wantStatus: domain.StatusAvailable, wantAddresses: []string{"192.0.2.1"}},
+		{link: "dns://up.test?expect=192.0.2.9", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassDNS, wantAddresses: []string{"192.0.2.1"}},
+		{link: "dns://missing.test", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassDNS},
+		{link: "tls://example.com", wantStatus: domain.StatusAvailable},
+		{link: "tls://wrong.test:443", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassTLS},
+		{link: "ftp://up.test", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassInvalid},
+		{link: "echo://up.test/ok", wantStatus: domain.StatusAvailable},
+		{link: "echo://up.test/down", wantStatus: domain.StatusNotAvailable, wantErrorClass: domain.ErrorClassHTTP},
+	}
+
+	links := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		links = append(links, tt.link)
+	}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			result := results(rec)[tt.link]
+			assert.Equal(t, tt.wantStatus, result.Status)
+			assert.Equal(t, tt.wantErrorClass, result.ErrorClass)
+			assert.Equal(t, tt.wantAddresses, result.Addresses)
+		})
+	}
+
+	assert.NotNil(t, results(rec)["tls://example.com"].TLS)
+	if assert.NotNil(t, results(rec)["tls://wrong.test:443"].TLS) {
+		assert.True(t, results(rec)["tls://wrong.test:443"].TLS.HostnameMismatch)
+	}
+}
+
+func TestAddressGuard(t *testing.T) {
+	network := newTestNetwork(t)
+
+	var blocked, allowed CIDRs
+	assert.NoError(t, blocked.SetValue("198.51.100.0/24"))
+	assert.NoError(t, allowed.SetValue("10.1.0.0/16"))
+	assert.Error(t, new(CIDRs).SetValue("10.0.0.0/33"))
+
+	cfg := &Config{
+		PingTimeout:          5 * time.Second,
+		Workers:              4,
+		MaxConnections:       8,
+		FollowRedirects:      true,
+		BlockPrivateNetworks: true,
+		BlockedCIDRs:         blocked,
+		AllowedCIDRs:         allowed,
+	}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	tests := []struct {
+		link       string
+		wantStatus string
+	}{
+		{link: "http://up.test", wantStatus: domain.StatusAvailable},
+		{link: "http://local.test", wantStatus: domain.StatusBlocked},
+		{link: "http://metadata.test", wantStatus: domain.StatusBlocked},
+		{link: "http://internal.test", wantStatus: domain.StatusBlocked},
+		{link: "http://127.0.0.1", wantStatus: domain.StatusBlocked},
+		{link: "http://[::1]", wantStatus: domain.StatusBlocked},
+		{link: "http://sneaky.test", wantStatus: domain.StatusBlocked},
+		{link: "http://mixed.test", wantStatus: domain.StatusAvailable},
+		{link: "http://doc.test", wantStatus: domain.StatusBlocked},
+		{link: "http://allowed.test", wantStatus: domain.StatusAvailable},
+		{link: "tcp://local.test:80", wantStatus: domain.StatusBlocked},
+		{link: "tls://internal.test", wantStatus: domain.StatusBlocked},
+		{link: "http://cgnat.test", wantStatus: domain.StatusBlocked},
+		{link: "http://zero.test", wantStatus: domain.StatusBlocked},
+		{link: "http://bench.test", wantStatus: domain.StatusBlocked},
+		{link: "http://future.test", wantStatus: domain.StatusBlocked},
+		{link: "http://bcast.test", wantStatus: domain.StatusBlocked},
+		{link: "http://255.255.255.255", wantStatus: domain.StatusBlocked},
+		{link: "http://nat64.test", wantStatus: domain.StatusBlocked},
+		{link: "http://teredo.test", wantStatus: domain.StatusBlocked},
+		{link: "http://6to4.test", wantStatus: domain.StatusBlocked},
+		{link: "http://[64:ff9b::c000:201]", wantStatus: domain.StatusBlocked},
+		{link: "dns://internal.test", wantStatus: domain.StatusBlocked},
+		{link: "dns://mixed.test", wantStatus: domain.StatusAvailable},
+	}
+
+	links := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		links = append(links, tt.link)
+	}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, results(rec)[tt.link].Status)
+		})
+	}
+
+	assert.Len(t, results(rec)["http://sneaky.test"].Redirects, 1, "redirect targets must be checked too")
+	assert.Empty(t, results(rec)["dns://internal.test"].Addresses, "blocked addresses must not be reported")
+	assert.NotContains(t, results(rec)["dns://internal.test"].Error, "10.0.0.5")
+	assert.Equal(t, []string{"192.0.2.1"}, results(rec)["dns://mixed.test"].Addresses)
+
+	assert.True(t, srv.guard.allows(netip.MustParseAddr("2002:c000:201::1")), "6to4 of a public address must be allowed")
+	assert.False(t, srv.guard.allows(netip.MustParseAddr("::ffff:100.100.100.200")))
+
+	assert.NoError(t, srv.ValidateCallbackURL(context.Background(), "https://up.test/hook"))
+	assert.ErrorIs(t, srv.ValidateCallbackURL(context.Background(), "ftp://up.test/hook"), ErrInvalidCallbackURL)
+
+	err = srv.ValidateCallbackURL(context.Background(), "http://internal.test/hook")
+	assert.ErrorIs(t, err, ErrInvalidCallbackURL)
+	assert.ErrorIs(t, err, errBlocked)
+}
+
+func TestHostLimits(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{
+		PingTimeout:        5 * time.Second,
+		Workers:            8,
+		MaxConnections:     8,
+		RetryMaxAttempts:   1,
+		HostRateLimit:      20,
+		HostBurst:          1,
+		HostMaxConnections: 2,
+		RespectRobots:      true,
+	}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	t.Run("concurrency and rate", func(t *testing.T) {
+		links := []string{"http://crowded.test/1", "http://crowded.test/2", "http://crowded.test/3", "http://crowded.test/4", "http://crowded.test/5"}
+
+		start := time.Now()
+		rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+		assert.NoError(t, err)
+
+		for _, link := range links {
+			assert.Equal(t, domain.StatusAvailable, results(rec)[link].Status)
+		}
+		assert.LessOrEqual(t, network.maxCrowd.Load(), int32(2))
+		assert.GreaterOrEqual(t, time.Since(start), 240*time.Millisecond, "the 5 checks after the robots.txt fetch must wait 50ms each")
+	})
+
+	t.Run("rate limited and robots", func(t *testing.T) {
+		tests := []struct {
+			link       string
+			wantStatus string
+		}{
+			{link: "http://throttle.test", wantStatus: domain.StatusRateLimited},
+			{link: "http://polite.test/public", wantStatus: domain.StatusAvailable},
+			{link: "http://polite.test/private/page", wantStatus: domain.StatusDisallowed},
+			{link: "http://polite.test/private/open", wantStatus: domain.StatusAvailable},
+		}
+
+		links := make([]string, 0, len(tests))
+		for _, tt := range tests {
+			links = append(links, tt.link)
+		}
+
+		rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+		assert.NoError(t, err)
+
+		for _, tt := range tests {
+			assert.Equal(t, tt.wantStatus, results(rec)[tt.link].Status, tt.link)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, results(rec)["http://throttle.test"].StatusCode)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(&Config{BreakerFailures: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+
+	assert.True(t, b.allow("down.test", now))
+	b.failure("down.test", now)
+	assert.True(t, b.allow("down.test", now), "one failure must not open the circuit")
+	b.failure("down.test", now)
+
+	assert.False(t, b.allow("down.test", now.Add(time.Second)))
+	assert.True(t, b.allow("up.test", now), "other hosts are not affected")
+
+	circuits := b.circuits()
+	assert.Len(t, circuits, 1)
+	assert.Equal(t, domain.CircuitOpen, circuits[0].State)
+	assert.Equal(t, 2, circuits[0].Failures)
+
+	// After the cooldown a single probe is let through.
+	later := now.Add(time.Minute)
+	assert.True(t, b.allow("down.test", later))
+	assert.False(t, b.allow("down.test", later), "only one probe may run")
+	assert.Equal(t, domain.CircuitHalfOpen, b.circuits()[0].State)
+
+	// A failed probe opens the circuit for another cooldown.
+	b.failure("down.test", later)
+	assert.False(t, b.allow("down.test", later.Add(time.Second)))
+
+	// An aborted probe lets the next check probe.
+	later = later.Add(time.Minute)
+	assert.True(t, b.allow("down.test", later))
+	b.cancel("down.test")
+	assert.True(t, b.allow("down.test", later))
+
+	// A successful probe closes the circuit.
+	b.success("down.test")
+	assert.True(t, b.allow("down.test", later))
+	assert.Empty(t, b.circuits())
+}
+
+func TestCircuitOpen(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{
+		PingTimeout:      5 * time.Second,
+		Workers:          1,
+		MaxConnections:   1,
+		RetryMaxAttempts: 1,
+		BreakerFailures:  2,
+		BreakerCooldown:  time.Minute,
+	}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	links := []string{"http://missing.test/1", "http://missing.test/2", "http://missing.test/3", "http://down.test/1", "http://down.test/2", "http://down.test/3"}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, domain.StatusNotAvailable, results(rec)["http://missing.test/2"].Status)
+	assert.Equal(t, domain.StatusCircuitOpen, results(rec)["http://missing.test/3"].Status)
+	assert.Equal(t, domain.StatusNotAvailable, results(rec)["http://down.test/3"].Status, "hosts answering with errors are reachable")
+
+	circuits := srv.Circuits()
+	assert.Len(t, circuits, 1)
+	assert.Equal(t, "missing.test", circuits[0].Host)
+	assert.Equal(t, domain.CircuitOpen, circuits[0].State)
+}
+
+func TestCache(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{
+		PingTimeout:    5 * time.Second,
+		Workers:        4,
+		MaxConnections: 8,
+		CacheTTL:       time.Minute,
+		CacheSize:      10,
+	}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	links := []string{"http://lazy.test"}
+
+	// Concurrent checks of the same link share one request.
+	var wg sync.WaitGroup
+	caches := make(chan string, 2)
+	for range 2 {
+		wg.Go(func() {
+			rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+			assert.NoError(t, err)
+			assert.Equal(t, domain.StatusAvailable, results(rec)["http://lazy.test"].Status)
+
+			caches <- results(rec)["http://lazy.test"].Cache
+		})
+	}
+	wg.Wait()
+	close(caches)
+
+	var got []string
+	for cache := range caches {
+		got = append(got, cache)
+	}
+	assert.ElementsMatch(t, []string{domain.CacheMiss, domain.CacheHit}, got)
+	assert.Equal(t, int32(1), network.lazy.Load())
+
+	tests := []struct {
+		name         string
+		opts         Options
+		wantCache    string
+		wantRequests int32
+	}{
+		{name: "cached", opts: Options{}, wantCache: domain.CacheHit, wantRequests: 1},
+		{name: "no cache", opts: Options{NoCache: true}, wantCache: domain.CacheBypass, wantRequests: 2},
+		{name: "other policy", opts: Options{Availability: &domain.Availability{Statuses: []domain.StatusRange{{From: 200, To: 399}}}}, wantCache: domain.CacheMiss, wantRequests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := srv.Process(context.Background(), context.Background(), links, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCache, results(rec)["http://lazy.test"].Cache)
+			assert.Equal(t, tt.wantRequests, network.lazy.Load())
+		})
+	}
+}
+
+func TestNormalizeLink(t *testing.T) {
+	srv := New(memory.New(zap.NewNop()), nil, &Config{PingTimeout: time.Second}, zap.NewNop())
+
+	tests := []struct {
+		link    string
+		want    string
+		wantErr bool
+	}{
+		{link: "Google.com", want: "https://google.com/"},
+		{link: "  https://google.com/  ", want: "https://google.com/"},
+		{link: "HTTPS://Google.COM:443", want: "https://google.com/"},
+		{link: "http://example.com:80/a/?q=1#top", want: "http://example.com/a/?q=1"},
+		{link: "http://example.com:8080/a", want: "http://example.com:8080/a"},
+		{link: "https://пример.рф/путь", want: "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{link: "http://[::1]:80", want: "http://[::1]/"},
+		{link: "tcp://DB.example.com:05432", want: "tcp://db.example.com:5432"},
+		{link: "tls://example.com:443", want: "tls://example.com"},
+		{link: "dns://Example.com?expect=192.0.2.1", want: "dns://example.com?expect=192.0.2.1"},
+		{link: "", wantErr: true},
+		{link: "ftp://example.com", wantErr: true},
+		{link: "http://", wantErr: true},
+		{link: "http://exa mple.com", wantErr: true},
+		{link: "http://example.com:99999", wantErr: true},
+		{link: "http://ex_ample.com", wantErr: true},
+		{link: "tcp://db.example.com", wantErr: true},
+		{link: "dns://example.com?expect=192.0.2", wantErr: true},
+		{link: "dns://example.com?expect=192.0.2.1,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, err := srv.NormalizeLink(tt.link)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLinkOrder(t *testing.T) {
+	network := newTestNetwork(t)
+	srv := New(memory.New(zap.NewNop()), nil, &Config{PingTimeout: 5 * time.Second, Workers: 4, MaxConnections: 4}, zap.NewNop(), network.options()...)
+
+	links := []string{"http://lazy.test", "http://down.test", "HTTP://Lazy.test:80/", "http://lazy.test"}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	want := []struct {
+		link       string
+		normalized string
+		status     string
+	}{
+		{link: "http://lazy.test", normalized: "http://lazy.test/", status: domain.StatusAvailable},
+		{link: "http://down.test", normalized: "http://down.test/", status: domain.StatusNotAvailable},
+		{link: "HTTP://Lazy.test:80/", normalized: "http://lazy.test/", status: domain.StatusAvailable},
+		{link: "http://lazy.test", normalized: "http://lazy.test/", status: domain.StatusAvailable},
+	}
+
+	if assert.Len(t, rec.Links, len(want)) {
+		for i, w := range want {
+			assert.Equal(t, i, rec.Links[i].Index)
+			assert.Equal(t, w.link, rec.Links[i].Link)
+			assert.Equal(t, w.normalized, rec.Links[i].Normalized)
+			assert.Equal(t, w.status, rec.Links[i].Result.Status)
+		}
+	}
+
+	// Spellings of the same link are checked once.
+	assert.Equal(t, int32(1), network.lazy.Load())
+}
+
+func TestRobotsRules(t *testing.T) {
+	robots := `# comment
+User-agent: *
+Disallow: /
+
+User-agent: other-bot
+User-agent: link-service
+Disallow: /admin
+Allow: /admin/public
+Disallow: /*.pdf$
+Disallow: /tmp*/cache
+`
+	rules := parseRobots(strings.NewReader(robots), userAgent)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/", want: true},
+		{path: "/docs", want: true},
+		{path: "/admin", want: false},
+		{path: "/admin/users", want: false},
+		{path: "/admin/public/page", want: true},
+		{path: "/files/report.pdf", want: false},
+		{path: "/files/report.pdf?download=1", want: true},
+		{path: "/tmp/1/cache", want: false},
+		{path: "/tmp/1/data", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, rules.allows(tt.path))
+		})
+	}
+
+	assert.False(t, parseRobots(strings.NewReader(robots), "unknown").allows("/docs"), "the * group applies to unknown agents")
+	assert.True(t, parseRobots(strings.NewReader(robots), "Link-Service/1.0").allows("/docs"), "groups match the product token")
+
+	prefix := "User-agent: link\nDisallow: /\n"
+	assert.True(t, parseRobots(strings.NewReader(prefix), userAgent).allows("/"), "a group must name the whole product token")
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []domain.StatusRange
+		wantErr bool
+	}{
+		{value: "200-299", want: []domain.StatusRange{{From: 200, To: 299}}},
+		{value: "200-299, 304,401-403", want: []domain.StatusRange{{From: 200, To: 299}, {From: 304, To: 304}, {From: 401, To: 403}}},
+		{value: "", wantErr: true},
+		{value: "299-200", wantErr: true},
+		{value: "2xx", wantErr: true},
+		{value: "200-700", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseStatusRanges(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC) // Monday
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "@every 5m", want: from.Add(5 * time.Minute)},
+		{spec: "90s", want: from.Add(90 * time.Second)},
+		{spec: "*/15 * * * *", want: time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{spec: "0 9-17 * * 1-5", want: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "30 6 * * 7", want: time.Date(2024, 1, 7, 6, 30, 0, 0, time.UTC)},
+		{spec: "0 0 1,15 * *", want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{spec: "0 0 31 * 3", want: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{spec: "@daily", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{spec: "30s", wantErr: true},
+		{spec: "@every 0s", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "0 0 30 2 *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseSchedule(tt.spec, time.Minute)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.next(from))
+		})
+	}
+}
+
+func TestParseScheduleMinInterval(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "*/15 * * * *"},
+		{spec: "0 9-17 * * 1-5"},
+		{spec: "@monthly"},
+		{spec: "*/5 * * * *", wantErr: true},
+		// 55 and 5 of the next hour are 10 minutes apart.
+		{spec: "5,55 * * * *", wantErr: true},
+		// 23:55 and 0:05 of the next day are 10 minutes apart.
+		{spec: "5,55 0,23 * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parseSchedule(tt.spec, 15*time.Minute)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errInvalidSchedule)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	network := newTestNetwork(t)
+	storage := memory.New(zap.NewNop())
+	cfg := &Config{PingTimeout: 5 * time.Second, Workers: 2, MaxConnections: 2, MonitorMinInterval: time.Minute, MonitorHistory: 2}
+
+	srv := New(storage, nil, cfg, zap.NewNop(), network.options()...)
+	assert.NoError(t, srv.LoadMonitors())
+
+	_, err := srv.CreateMonitor("site", []string{"http://up.test"}, "@every 10ms", Options{})
+	assert.ErrorIs(t, err, ErrInvalidMonitor, "intervals below the minimum must be rejected")
+
+	srv.monitorMinInterval = 0
+
+	up, err := srv.CreateMonitor("site", []string{"http://up.test"}, "@every 10ms", Options{})
+	assert.NoError(t, err)
+
+	_, err = srv.CreateMonitor("site", []string{"http://down.test"}, "@every 10ms", Options{})
+	assert.ErrorIs(t, err, ErrMonitorExists)
+
+	down, err := srv.CreateMonitor("api", []string{"http://up.test", "http://down.test"}, "@every 10ms", Options{})
+	assert.NoError(t, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	go srv.RunMonitors(ctx)
+
+	assert.Eventually(t, func() bool {
+		m, err := srv.Monitor(down.ID)
+		return err == nil && len(m.Runs) == cfg.MonitorHistory
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stop()
+	assert.NoError(t, srv.Shutdown(context.Background()))
+
+	m, err := srv.Monitor(down.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, m.Uptime)
+
+	rec, err := storage.GetRecord(m.Runs[len(m.Runs)-1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, down.ID, rec.MonitorID)
+	assert.Equal(t, domain.StatusNotAvailable, results(rec)["http://down.test"].Status)
+
+	// Monitors and their history survive a restart.
+	restarted := New(storage, nil, cfg, zap.NewNop(), network.options()...)
+	assert.NoError(t, restarted.LoadMonitors())
+
+	monitors := restarted.Monitors()
+	if assert.Len(t, monitors, 2) {
+		assert.Equal(t, up.ID, monitors[0].ID)
+		assert.Equal(t, "site", monitors[0].Name)
+		assert.NotEmpty(t, monitors[0].Runs)
+		assert.Equal(t, 1.0, monitors[0].Uptime)
+	}
+
+	assert.NoError(t, restarted.DeleteMonitor(up.ID))
+	assert.ErrorIs(t, restarted.DeleteMonitor(up.ID), ErrMonitorNotFound)
+
+	created, err := restarted.CreateMonitor("docs", []string{"http://up.test"}, "@hourly", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, down.ID+1, created.ID, "monitor IDs must not be reused")
+
+	// The ID of the deleted highest monitor is not reused after a restart
+	// either, records of its runs still point to it.
+	assert.NoError(t, restarted.DeleteMonitor(created.ID))
+
+	restarted = New(storage, nil, cfg, zap.NewNop(), network.options()...)
+	assert.NoError(t, restarted.LoadMonitors())
+
+	created, err = restarted.CreateMonitor("docs", []string{"http://up.test"}, "@hourly", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, down.ID+2, created.ID, "the ID of a deleted monitor must not be reused after a restart")
+}
+
+func TestRetry(t *testing.T) {
+	network := newTestNetwork(t)
+
+	cfg := &Config{
+		PingTimeout:         5 * time.Second,
+		Workers:             4,
+		MaxConnections:      8,
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: time.Millisecond,
+		RetryMaxBackoff:     10 * time.Millisecond,
+		RetryErrorClasses:   []string{domain.ErrorClassConnect, domain.ErrorClassTimeout, domain.ErrorClassReset},
+		RetryStatusCodes:    []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), network.options()...)
+
+	links := []string{"http://flaky.test", "http://reset.test", "http://busy.test", "http://throttle.test", "http://down.test", "http://missing.test"}
+
+	rec, err := srv.Process(context.Background(), context.Background(), links, Options{})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		link         string
+		wantStatus   string
+		wantAttempts int
+	}{
+		{link: "http://flaky.test", wantStatus: domain.StatusAvailable, wantAttempts: 3},
+		{link: "http://reset.test", wantStatus: domain.StatusAvailable, wantAttempts: 2},
+		{link: "http://busy.test", wantStatus: domain.StatusNotAvailable, wantAttempts: 3},
+		{link: "http://throttle.test", wantStatus: domain.StatusRateLimited, wantAttempts: 3},
+		{link: "http://down.test", wantStatus: domain.StatusNotAvailable, wantAttempts: 1},
+		{link: "http://missing.test", wantStatus: domain.StatusNotAvailable, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, results(rec)[tt.link].Status)
+			assert.Equal(t, tt.wantAttempts, results(rec)[tt.link].Attempts)
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: domain.ErrorClassConnect},
+		{name: "reset", err: &url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, want: domain.ErrorClassReset},
+		{name: "broken pipe", err: &net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, want: domain.ErrorClassReset},
+		{name: "truncated response", err: fmt.Errorf("failed to read body: %w", io.ErrUnexpectedEOF), want: domain.ErrorClassReset},
+		{name: "other", err: errors.New("malformed response"), want: domain.ErrorClassHTTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyError(tt.err))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := retryPolicy{
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     time.Second,
+	}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{name: "first attempt", attempt: 1, wantMin: 50 * time.Millisecond, wantMax: 100 * time.Millisecond},
+		{name: "third attempt", attempt: 3, wantMin: 200 * time.Millisecond, wantMax: 400 * time.Millisecond},
+		{name: "capped", attempt: 10, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "retry after", attempt: 1, retryAfter: 700 * time.Millisecond, wantMin: 700 * time.Millisecond, wantMax: 700 * time.Millisecond},
+		{name: "retry after capped", attempt: 1, retryAfter: time.Minute, wantMin: time.Second, wantMax: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay := policy.delay(tt.attempt, tt.retryAfter)
+			assert.GreaterOrEqual(t, delay, tt.wantMin)
+			assert.LessOrEqual(t, delay, tt.wantMax)
 		})
 	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+// recordSummary is a record with its links keyed by the submitted link.
+type recordSummary struct {
+	Links map[string]domain.LinkResult
+	ID    int64
+}
+
+// summary strips the fields that vary between runs, such as latency and
+// timestamps, from rec's link results.
+func summary(rec *domain.Record) *recordSummary {
+	if rec == nil {
+		return nil
+	}
+
+	links := make(map[string]domain.LinkResult, len(rec.Links))
+	for link, result := range results(rec) {
+		links[link] = domain.LinkResult{
+			Status:     result.Status,
+			StatusCode: result.StatusCode,
+			FinalURL:   result.FinalURL,
+			ErrorClass: result.ErrorClass,
+			Redirects:  result.Redirects,
+		}
+	}
+
+	return &recordSummary{Links: links, ID: rec.ID}
+}
+
+// results returns the results of rec keyed by the submitted link.
+func results(rec *domain.Record) map[string]domain.LinkResult {
+	links := make(map[string]domain.LinkResult, len(rec.Links))
+	for _, entry := range rec.Links {
+		links[entry.Link] = entry.Result
+	}
+
+	return links
+}
+
+// testEntries returns the entries of links, sorted as in records saved before
+// links were kept in order.
+func testEntries(links map[string]domain.LinkResult) []domain.LinkEntry {
+	entries := make([]domain.LinkEntry, 0, len(links))
+	for i, link := range slices.Sorted(maps.Keys(links)) {
+		entries = append(entries, domain.LinkEntry{Index: i, Link: link, Normalized: link, Result: links[link]})
+	}
+
+	return entries
+}
+
+// testHosts are the addresses of the fake hosts. 192.0.2.1 is the public
+// address of the test servers.
+var testHosts = map[string][]string{
+	"up.test":       {"192.0.2.1"},
+	"down.test":     {"192.0.2.1"},
+	"moved.test":    {"192.0.2.1"},
+	"far.test":      {"192.0.2.1"},
+	"loop.test":     {"192.0.2.1"},
+	"slow.test":     {"192.0.2.1"},
+	"busy.test":     {"192.0.2.1"},
+	"flaky.test":    {"192.0.2.1"},
+	"lazy.test":     {"192.0.2.1"},
+	"empty.test":    {"192.0.2.1"},
+	"private.test":  {"192.0.2.1"},
+	"wrong.test":    {"192.0.2.1"},
+	"example.com":   {"192.0.2.1"},
+	"local.test":    {"127.0.0.1"},
+	"metadata.test": {"169.254.169.254"},
+	"internal.test": {"10.0.0.5"},
+	"mixed.test":    {"10.0.0.5", "192.0.2.1"},
+	"doc.test":      {"198.51.100.7"},
+	"allowed.test":  {"10.1.2.3"},
+	"sneaky.test":   {"192.0.2.1"},
+	"crowded.test":  {"192.0.2.1"},
+	"throttle.test": {"192.0.2.1"},
+	"polite.test":   {"192.0.2.1"},
+	"reset.test":    {"192.0.2.1"},
+	"cgnat.test":    {"100.100.100.200"},
+	"zero.test":     {"0.1.2.3"},
+	"bench.test":    {"198.18.0.1"},
+	"future.test":   {"240.0.0.1"},
+	"bcast.test":    {"255.255.255.255"},
+	// 10.0.0.5 through NAT64 and Teredo, 169.254.169.254 through 6to4.
+	"nat64.test":  {"64:ff9b::a00:5"},
+	"teredo.test": {"2001:0:4136:e378:8000:63bf:f5ff:fffa"},
+	"6to4.test":   {"2002:a9fe:a9fe::1"},
+}
+
+// testNetwork serves fake hosts from local test servers:
+//   - up.test, example.com, mixed.test, allowed.test and internal.test
+//     answer 200,
+//   - down.test answers 500,
+//   - moved.test redirects to up.test,
+//   - far.test/<n> redirects to far.test/<n-1> until far.test/0, which answers 200,
+//   - loop.test redirects to itself,
+//   - sneaky.test redirects to internal.test,
+//   - slow.test never answers,
+//   - lazy.test answers 200 after 50ms and counts requests,
+//   - empty.test answers 204,
+//   - private.test answers 401,
+//   - busy.test answers 503,
+//   - flaky.test answers 503 to the first two requests and 200 afterwards,
+//   - crowded.test answers 200 after 20ms and counts concurrent requests,
+//   - throttle.test answers 429,
+//   - polite.test answers 200 and disallows /private in its robots.txt,
+//   - reset.test resets the connections of the first two requests, the HEAD
+//     and GET of a first attempt, and answers 200 afterwards,
+//
+// hosts missing from testHosts fail to resolve. Plain http is served on port
+// 80 and https, with a certificate for example.com, on port 443. wrong.test resolves
+// to the same servers, so its certificate does not match the host name.
+type testNetwork struct {
+	flaky     atomic.Int32
+	reset     atomic.Int32
+	crowd     atomic.Int32
+	lazy      atomic.Int32
+	maxCrowd  atomic.Int32
+	server    *httptest.Server
+	tlsServer *httptest.Server
+	rootCAs   *x509.CertPool
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+
+	network := &testNetwork{
+		rootCAs: x509.NewCertPool(),
+	}
+
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "up.test", "example.com", "mixed.test", "allowed.test", "internal.test":
+			w.WriteHeader(http.StatusOK)
+		case "down.test":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "lazy.test":
+			network.lazy.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		case "empty.test":
+			w.WriteHeader(http.StatusNoContent)
+		case "private.test":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "busy.test":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "flaky.test":
+			if network.flaky.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		case "moved.test":
+			http.Redirect(w, r, "http://up.test/", http.StatusFound)
+		case "far.test":
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+			if n == 0 {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusMovedPermanently)
+		case "loop.test":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "crowded.test":
+			crowd := network.crowd.Add(1)
+			defer network.crowd.Add(-1)
+
+			for {
+				current := network.maxCrowd.Load()
+				if crowd <= current || network.maxCrowd.CompareAndSwap(current, crowd) {
+					break
+				}
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		case "throttle.test":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "polite.test":
+			if r.URL.Path == "/robots.txt" {
+				_, _ = io.WriteString(w, "User-agent: *\nDisallow: /private\nAllow: /private/open\n")
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		case "sneaky.test":
+			http.Redirect(w, r, "http://internal.test/", http.StatusFound)
+		case "reset.test":
+			if network.reset.Add(1) > 2 {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+
+			// Closing with a zero linger sends a RST instead of a FIN.
+			if tcpConn, ok := conn.(*net.TCPConn); ok {
+				_ = tcpConn.SetLinger(0)
+			}
+			_ = conn.Close()
+		case "slow.test":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	network.server = httptest.NewServer(handler)
+	network.tlsServer = httptest.NewTLSServer(handler)
+	network.rootCAs.AddCert(network.tlsServer.Certificate())
+
+	t.Cleanup(func() {
+		close(release)
+		network.server.Close()
+		network.tlsServer.Close()
+	})
+
+	return network
+}
+
+func (n *testNetwork) options() []Option {
+	return []Option{
+		WithResolver(n),
+		WithDialer(n),
+		WithTLSConfig(&tls.Config{RootCAs: n.rootCAs}),
+	}
+}
+
+func (n *testNetwork) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := testHosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return addrs, nil
+}
+
+// DialContext connects every address on port 80 to the http server and on
+// port 443 to the https one, any other port refuses connections.
+func (n *testNetwork) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var server *httptest.Server
+	switch port {
+	case "80":
+		server = n.server
+	case "443":
+		server = n.tlsServer
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+}
+
+// failingRepository fails the first failures record saves.
+type failingRepository struct {
+	*memory.Storage
+	failures atomic.Int32
+}
+
+func (r *failingRepository) SaveRecord(rec *domain.Record) error {
+	if r.failures.Add(-1) >= 0 {
+		return errors.New("disk is full")
+	}
+
+	return r.Storage.SaveRecord(rec)
+}
+
+func TestIDsNotReused(t *testing.T) {
+	network := newTestNetwork(t)
+
+	repo := &failingRepository{Storage: memory.New(zap.NewNop())}
+	repo.failures.Store(1)
+
+	srv := New(repo, nil, &Config{PingTimeout: 5 * time.Second, Workers: 1, MaxConnections: 1}, zap.NewNop(), network.options()...)
+
+	_, err := srv.Process(context.Background(), context.Background(), []string{"http://up.test"}, Options{})
+	assert.Error(t, err)
+
+	// Another request may already hold the next ID, so the failed one leaves
+	// a gap.
+	rec, err := srv.Process(context.Background(), context.Background(), []string{"http://up.test"}, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rec.ID)
+}
+
+func TestProcessTimeout(t *testing.T) {
+	release := make(chan struct{})
+	linkServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer linkServer.Close()
+	defer close(release)
+
+	storage := memory.New(zap.NewNop())
+	srv := New(storage, nil, &Config{PingTimeout: 10 * time.Second, Workers: 2, MaxConnections: 2}, zap.NewNop())
+
+	fastLink := linkServer.URL + "/fast"
+	slowLink := linkServer.URL + "/slow"
+
+	requestCtx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	rec, err := srv.Process(context.Background(), requestCtx, []string{fastLink, slowLink}, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rec.ID)
+	assert.Equal(t, domain.StatusAvailable, results(rec)[fastLink].Status)
+	assert.Equal(t, domain.StatusTimeout, results(rec)[slowLink].Status)
+
+	saved, err := storage.GetRecord(1)
+	assert.NoError(t, err)
+	assert.Equal(t, rec, saved)
+}
+
+func TestProcessTempRecords(t *testing.T) {
+	storage := memory.New(zap.NewNop())
+
+	assert.NoError(t, storage.SaveRecord(&domain.Record{ID: 1}))
+	assert.NoError(t, storage.SaveTempRecord(&domain.Record{
+		Links: testEntries(map[string]domain.LinkResult{
+			"http://up.test": {Status: domain.StatusUnknown},
+		}),
+		ID: 3,
+	}))
+	assert.NoError(t, storage.SaveTempRecord(&domain.Record{
+		Links: testEntries(map[string]domain.LinkResult{
+			"http://down.test": {Status: domain.StatusUnknown},
+			// Checked before the interruption, must not be checked again.
+			"http://missing.test": {Status: domain.StatusAvailable},
+		}),
+		ID: 2,
+	}))
+	assert.NoError(t, storage.SaveTempRecord(&domain.Record{ID: 1}))
+
+	network := newTestNetwork(t)
+	srv := New(storage, nil, &Config{PingTimeout: 5 * time.Second, Workers: 1, MaxConnections: 1, JobWorkers: 1}, zap.NewNop(), network.options()...)
+	assert.NoError(t, srv.ProcessTempRecords())
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go srv.RunJobs(ctx)
+
+	wantRecs := map[int64]*recordSummary{
+		2: {
+			Links: map[string]domain.LinkResult{
+				"http://down.test":    {Status: domain.StatusNotAvailable, StatusCode: http.StatusInternalServerError, FinalURL: "http://down.test", ErrorClass: domain.ErrorClassHTTP},
+				"http://missing.test": {Status: domain.StatusAvailable},
+			},
+			ID: 2,
+		},
+		3: {
+			Links: map[string]domain.LinkResult{
+				"http://up.test": {Status: domain.StatusAvailable, StatusCode: http.StatusOK, FinalURL: "http://up.test"},
+			},
+			ID: 3,
+		},
+	}
+
+	for id, wantRec := range wantRecs {
+		assert.Eventually(t, func() bool {
+			job, err := srv.Job(id)
+			return err == nil && job.State == domain.JobStateDone
+		}, 5*time.Second, 10*time.Millisecond)
+
+		rec, err := storage.GetRecord(id)
+		assert.NoError(t, err)
+		assert.Equal(t, wantRec, summary(rec))
+	}
+
+	tempRecords, err := storage.LoadTempRecords()
+	assert.NoError(t, err)
+	assert.Empty(t, tempRecords)
+
+	stoppedCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec, err := srv.Process(stoppedCtx, context.Background(), []string{"http://up.test"}, Options{})
+	assert.ErrorIs(t, err, ErrAppStopped)
+	assert.Equal(t, int64(4), rec.ID)
+}
+
+func TestSubmit(t *testing.T) {
+	storage := memory.New(zap.NewNop())
+	srv := New(storage, nil, &Config{PingTimeout: time.Second, Workers: 1, MaxConnections: 1, QueueSize: 1}, zap.NewNop())
+
+	job, err := srv.Submit(context.Background(), nil, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Job{ID: 1, State: domain.JobStateQueued, Links: []domain.LinkEntry{}}, job)
+
+	_, err = srv.Submit(context.Background(), nil, Options{})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go srv.RunJobs(ctx)
+
+	assert.Eventually(t, func() bool {
+		job, err := srv.Job(1)
+		return err == nil && job.State == domain.JobStateDone
+	}, time.Second, 10*time.Millisecond)
+
+	rec, err := storage.GetRecord(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rec.ID)
+
+	_, err = srv.Job(2)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestSubmitQueueLimit(t *testing.T) {
+	srv := New(memory.New(zap.NewNop()), nil, &Config{PingTimeout: time.Second, Workers: 1, MaxConnections: 1, QueueSize: 3}, zap.NewNop())
+
+	var (
+		wg       sync.WaitGroup
+		accepted atomic.Int32
+	)
+	for range 20 {
+		wg.Go(func() {
+			_, err := srv.Submit(context.Background(), []string{"http://up.test"}, Options{})
+			if err == nil {
+				accepted.Add(1)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrQueueFull)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), accepted.Load(), "concurrent submits must not exceed the queue size")
+}
+
+func TestShutdown(t *testing.T) {
+	release := make(chan struct{})
+	linkServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer linkServer.Close()
+	defer close(release)
+
+	storage := memory.New(zap.NewNop())
+	srv := New(storage, nil, &Config{PingTimeout: 10 * time.Second, Workers: 2, MaxConnections: 2}, zap.NewNop())
+
+	fastLink := linkServer.URL + "/fast"
+	slowLink := linkServer.URL + "/slow"
+
+	type processResult struct {
+		rec *domain.Record
+		err error
+	}
+	done := make(chan processResult, 1)
+
+	go func() {
+		rec, err := srv.Process(context.Background(), context.Background(), []string{fastLink, slowLink}, Options{})
+		done <- processResult{rec: rec, err: err}
+	}()
+
+	assert.Eventually(t, func() bool { return srv.inflight.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	res := <-done
+	assert.ErrorIs(t, res.err, ErrAppStopped)
+	assert.Equal(t, int64(1), res.rec.ID)
+
+	tempRecords, err := storage.LoadTempRecords()
+	assert.NoError(t, err)
+	assert.Len(t, tempRecords, 1)
+	assert.Equal(t, int64(1), tempRecords[0].ID)
+	assert.Equal(t, domain.StatusAvailable, results(&tempRecords[0])[fastLink].Status)
+	assert.Equal(t, domain.StatusUnknown, results(&tempRecords[0])[slowLink].Status)
+}
+
+func TestShutdownGrace(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	stuck := CheckerFunc(func(_ context.Context, link string, _ domain.Availability) (domain.LinkResult, error) {
+		<-release
+		return domain.LinkResult{FinalURL: link}, nil
+	})
+
+	cfg := &Config{PingTimeout: 10 * time.Second, Workers: 1, MaxConnections: 1}
+	srv := New(memory.New(zap.NewNop()), nil, cfg, zap.NewNop(), WithChecker("stuck", stuck))
+	srv.drainGrace = 100 * time.Millisecond
+
+	go func() {
+		_, _ = srv.Process(context.Background(), context.Background(), []string{"stuck://up.test"}, Options{})
+	}()
+
+	assert.Eventually(t, func() bool { return srv.inflight.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := srv.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second, "a check ignoring cancellation must not hang the shutdown")
 }
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// timing collects the phases of a single request. DNS lookups and dials are
+// reported by dialContext, which resolves host names itself, the rest comes
+// from httptrace. Dials may run outside the request goroutine, hence the
+// mutex.
+type timing struct {
+	mu        *sync.Mutex
+	start     time.Time
+	dns       time.Duration
+	connect   time.Duration
+	tls       time.Duration
+	tlsStart  time.Time
+	firstByte time.Time
+}
+
+type timingKey struct{}
+
+func newTiming(start time.Time) *timing {
+	return &timing{
+		mu:    &sync.Mutex{},
+		start: start,
+	}
+}
+
+// withTiming attaches t to ctx, both for dialContext and as an httptrace
+// client trace.
+func withTiming(ctx context.Context, t *timing) context.Context {
+	ctx = context.WithValue(ctx, timingKey{}, t)
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if !t.tlsStart.IsZero() {
+				t.tls += time.Since(t.tlsStart)
+				t.tlsStart = time.Time{}
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.firstByte.IsZero() {
+				t.firstByte = time.Now()
+			}
+		},
+	})
+}
+
+func timingFromContext(ctx context.Context) *timing {
+	t, _ := ctx.Value(timingKey{}).(*timing)
+	return t
+}
+
+func (t *timing) addDNS(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dns += d
+}
+
+func (t *timing) addConnect(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.connect += d
+}
+
+func (t *timing) addTLS(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tls += d
+}
+
+// result returns the breakdown of a request that took total.
+func (t *timing) result(total time.Duration) *domain.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := &domain.Timing{
+		DNSMs:     t.dns.Milliseconds(),
+		ConnectMs: t.connect.Milliseconds(),
+		TLSMs:     t.tls.Milliseconds(),
+		TotalMs:   total.Milliseconds(),
+	}
+
+	if !t.firstByte.IsZero() {
+		res.TTFBMs = t.firstByte.Sub(t.start).Milliseconds()
+	}
+
+	return res
+}
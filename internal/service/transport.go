@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+// Dialer opens connections to resolved addresses. *net.Dialer implements it.
+type Dialer interface {
+	DialContext(ctx context.Context, network string, address string) (net.Conn, error)
+}
+
+// Resolver resolves host names of checked links. *net.Resolver implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Option configures how the Service reaches checked links.
+type Option func(*Service)
+
+// WithDialer replaces the dialer used to connect to checked links.
+func WithDialer(dialer Dialer) Option {
+	return func(s *Service) {
+		s.dialer = dialer
+	}
+}
+
+// WithResolver replaces the resolver used to look up checked links.
+func WithResolver(resolver Resolver) Option {
+	return func(s *Service) {
+		s.resolver = resolver
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for https links.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Service) {
+		s.tlsConfig = cfg
+	}
+}
+
+func (s *Service) newTransport() *http.Transport {
+	// A proxy would connect to checked links on behalf of the service, out of
+	// reach of the address guard.
+	proxy := http.ProxyFromEnvironment
+	if s.guard.enabled() {
+		proxy = nil
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           s.dialContext,
+		TLSClientConfig:       s.newTLSConfig(),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// dialContext resolves the host of address with s.resolver and dials the
+// resulting IPs in order with s.dialer until one of them accepts. IPs not
+// allowed by the address guard are skipped, if none is left the dial fails
+// with errBlocked.
+func (s *Service) dialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	timing := timingFromContext(ctx)
+
+	allowed, err := s.resolveAllowed(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, ip := range allowed {
+		start := time.Now()
+		conn, err := s.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		timing.addConnect(time.Since(start))
+		if err == nil {
+			return conn, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// DialContext dials address the way checks do, through the address guard.
+// It is meant for the other requests made to client supplied URLs, such as
+// callbacks.
+func (s *Service) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return s.dialContext(ctx, network, address)
+}
+
+// ValidateCallbackURL reports whether rawURL is an http(s) URL whose host
+// resolves to an address the address guard allows.
+func (s *Service) ValidateCallbackURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidCallbackURL, rawURL)
+	}
+
+	if !s.guard.enabled() {
+		return nil
+	}
+
+	_, err = s.resolveAllowed(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCallbackURL, err)
+	}
+
+	return nil
+}
+
+// resolveAllowed resolves host, unless it is an IP, and returns its IPs the
+// address guard allows.
+func (s *Service) resolveAllowed(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		start := time.Now()
+		addrs, err := s.resolver.LookupIPAddr(ctx, host)
+		timingFromContext(ctx).addDNS(time.Since(start))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	allowed := ips[:0:0]
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if ok && s.guard.allows(addr) {
+			allowed = append(allowed, ip)
+		}
+	}
+
+	// The blocked addresses are not named, so that errors cannot be used to
+	// map internal networks.
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("%w: %s resolves to blocked addresses only", errBlocked, host)
+	}
+
+	return allowed, nil
+}
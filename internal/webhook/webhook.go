@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+const (
+	SignatureHeader = "X-Signature-256"
+	LinksNumHeader  = "X-Links-Num"
+
+	signaturePrefix = "sha256="
+	queueSize       = 1024
+)
+
+// errRejected is a delivery the callback URL refused for good, it is not
+// retried.
+var errRejected = errors.New("callback rejected")
+
+type Config struct {
+	// Secret keys the HMAC signature of every callback.
+	Secret         string        `env:"WEBHOOK_SECRET" env-required:"true"`
+	Timeout        time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	MaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	InitialBackoff time.Duration `env:"WEBHOOK_INITIAL_BACKOFF" env-default:"1s"`
+	MaxBackoff     time.Duration `env:"WEBHOOK_MAX_BACKOFF" env-default:"5m"`
+}
+
+// Sender delivers saved records to the callback URLs their clients supplied.
+// Pending deliveries are kept in the repository until they succeed or run out
+// of attempts, so they survive restarts.
+type Sender struct {
+	repository     repository.Repository
+	httpClient     *http.Client
+	secret         []byte
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	queue          chan domain.Callback
+	inflightMu     *sync.Mutex
+	inflight       map[int64]struct{}
+	logger         *zap.Logger
+}
+
+// Option configures how the Sender reaches callback URLs.
+type Option func(*Sender)
+
+// WithDialContext makes callbacks connect through dial instead of directly,
+// and never through a proxy, so that dial can decide which addresses
+// callbacks may reach.
+func WithDialContext(dial func(ctx context.Context, network string, address string) (net.Conn, error)) Option {
+	return func(s *Sender) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dial
+
+		s.httpClient.Transport = transport
+	}
+}
+
+func New(repo repository.Repository, cfg *Config, logger *zap.Logger, opts ...Option) *Sender {
+	s := &Sender{
+		repository: repo,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		secret:         []byte(cfg.Secret),
+		maxAttempts:    max(cfg.MaxAttempts, 1),
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		queue:          make(chan domain.Callback, queueSize),
+		inflightMu:     &sync.Mutex{},
+		inflight:       make(map[int64]struct{}),
+		logger:         logger,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Notify persists a callback for rec and schedules its delivery. Records
+// without a callback URL are ignored.
+func (s *Sender) Notify(rec *domain.Record) {
+	if rec.CallbackURL == "" {
+		return
+	}
+
+	callback := domain.Callback{
+		ID:  rec.ID,
+		URL: rec.CallbackURL,
+	}
+
+	err := s.repository.SaveCallback(&callback)
+	if err != nil {
+		s.logger.Error("failed to save callback", zap.Int64("id", rec.ID), zap.Error(err))
+	}
+
+	select {
+	case s.queue <- callback:
+	default:
+		s.logger.Warn("callback queue is full, delivery postponed until restart", zap.Int64("id", rec.ID))
+	}
+}
+
+// Run delivers callbacks left over from the previous run and then every new
+// one until ctx is done. Undelivered callbacks stay in the repository.
+func (s *Sender) Run(ctx context.Context) {
+	callbacks, err := s.repository.LoadCallbacks()
+	if err != nil {
+		s.logger.Error("failed to load callbacks", zap.Error(err))
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, callback := range callbacks {
+		wg.Go(func() { s.deliver(ctx, callback) })
+	}
+
+	for {
+		select {
+		case callback := <-s.queue:
+			wg.Go(func() { s.deliver(ctx, callback) })
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Sender) deliver(ctx context.Context, callback domain.Callback) {
+	// A callback saved by Notify while Run is loading pending ones may
+	// arrive twice.
+	if !s.acquire(callback.ID) {
+		return
+	}
+	defer s.release(callback.ID)
+
+	rec, err := s.repository.GetRecord(callback.ID)
+	if err != nil {
+		s.logger.Error("failed to get callback record", zap.Int64("id", callback.ID), zap.Error(err))
+
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			s.forget(callback.ID)
+		}
+
+		return
+	}
+
+	body, err := json.Marshal(rec)
+	if err != nil {
+		s.logger.Error("failed to marshal callback record", zap.Int64("id", callback.ID), zap.Error(err))
+		return
+	}
+
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		err = s.send(ctx, callback, body)
+		if err == nil {
+			s.logger.Info("callback delivered", zap.Int64("id", callback.ID), zap.Int("attempt", attempt))
+			s.forget(callback.ID)
+			return
+		}
+
+		s.logger.Warn("failed to deliver callback",
+			zap.Int64("id", callback.ID),
+			zap.String("url", callback.URL),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+
+		if errors.Is(err, errRejected) {
+			s.logger.Error("callback rejected, dropping it", zap.Int64("id", callback.ID), zap.String("url", callback.URL), zap.Error(err))
+			s.forget(callback.ID)
+			return
+		}
+
+		if attempt == s.maxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(s.backoff(attempt)):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	s.logger.Error("giving up on callback", zap.Int64("id", callback.ID), zap.String("url", callback.URL))
+	s.forget(callback.ID)
+}
+
+func (s *Sender) send(ctx context.Context, callback domain.Callback, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to create request: %w", errRejected, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(LinksNumHeader, fmt.Sprint(callback.ID))
+
+	req.Header.Set(SignatureHeader, Sign(s.secret, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		// Only server errors and rate limiting may go away on their own.
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: unexpected status code: %d", errRejected, resp.StatusCode)
+		}
+
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the attempt following the given one.
+func (s *Sender) backoff(attempt int) time.Duration {
+	delay := s.initialBackoff << (attempt - 1)
+	if delay <= 0 || delay > s.maxBackoff {
+		return s.maxBackoff
+	}
+
+	return delay
+}
+
+func (s *Sender) acquire(id int64) bool {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+
+	if _, ok := s.inflight[id]; ok {
+		return false
+	}
+
+	s.inflight[id] = struct{}{}
+	return true
+}
+
+func (s *Sender) release(id int64) {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+
+	delete(s.inflight, id)
+}
+
+func (s *Sender) forget(id int64) {
+	err := s.repository.DeleteCallback(id)
+	if err != nil {
+		s.logger.Error("failed to delete callback", zap.Int64("id", id), zap.Error(err))
+	}
+}
+
+// Sign returns the value of SignatureHeader for body: the hex encoded
+// HMAC-SHA256 of body keyed with secret.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/memory"
+)
+
+func TestSender(t *testing.T) {
+	secret := []byte("secret")
+
+	var attempts atomic.Int32
+	delivered := make(chan domain.Record, 1)
+
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, Sign(secret, body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, "7", r.Header.Get(LinksNumHeader))
+
+		var rec domain.Record
+		assert.NoError(t, json.Unmarshal(body, &rec))
+
+		delivered <- rec
+	}))
+	defer callbackServer.Close()
+
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:           t.TempDir(),
+		FileName:          "data.json",
+		TempFileName:      "temp.json",
+		CallbacksFileName: "callbacks.json",
+		MonitorsFileName:  "monitors.json",
+		IndexFileName:     "data.idx",
+		Fsync:             "always",
+	}, zap.NewNop())
+	assert.NoError(t, err)
+
+	rec := &domain.Record{
+		Links:       []domain.LinkEntry{{Link: "google.com", Normalized: "google.com", Result: domain.LinkResult{Status: domain.StatusAvailable}}},
+		ID:          7,
+		CallbackURL: callbackServer.URL,
+	}
+	assert.NoError(t, storage.SaveRecord(rec))
+
+	sender := New(storage, &Config{
+		Secret:         string(secret),
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sender.Run(ctx)
+
+	sender.Notify(rec)
+
+	select {
+	case got := <-delivered:
+		assert.Equal(t, *rec, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+
+	assert.Eventually(t, func() bool {
+		callbacks, err := storage.LoadCallbacks()
+		return err == nil && len(callbacks) == 0
+	}, time.Second, 10*time.Millisecond, "delivered callback must be removed")
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestSenderDialContext(t *testing.T) {
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("callback must not reach a blocked address")
+	}))
+	defer callbackServer.Close()
+
+	storage := memory.New(zap.NewNop())
+
+	rec := &domain.Record{ID: 7, CallbackURL: callbackServer.URL}
+	assert.NoError(t, storage.SaveRecord(rec))
+
+	var dials atomic.Int32
+	blocked := func(ctx context.Context, network string, address string) (net.Conn, error) {
+		dials.Add(1)
+		return nil, errors.New("address is blocked")
+	}
+
+	sender := New(storage, &Config{
+		Secret:         "secret",
+		Timeout:        time.Second,
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}, zap.NewNop(), WithDialContext(blocked))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sender.Run(ctx)
+
+	sender.Notify(rec)
+
+	assert.Eventually(t, func() bool {
+		callbacks, err := storage.LoadCallbacks()
+		return err == nil && len(callbacks) == 0
+	}, time.Second, 10*time.Millisecond, "undeliverable callback must be given up on")
+	assert.Equal(t, int32(2), dials.Load(), "every attempt must dial through the given dialer")
+}
+
+func TestSenderRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int32
+	}{
+		{name: "bad request", status: http.StatusBadRequest, wantAttempts: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantAttempts: 1},
+		{name: "not found", status: http.StatusNotFound, wantAttempts: 1},
+		{name: "gone", status: http.StatusGone, wantAttempts: 1},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantAttempts: 3},
+		{name: "server error", status: http.StatusBadGateway, wantAttempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer callbackServer.Close()
+
+			storage := memory.New(zap.NewNop())
+
+			rec := &domain.Record{ID: 7, CallbackURL: callbackServer.URL}
+			assert.NoError(t, storage.SaveRecord(rec))
+
+			sender := New(storage, &Config{
+				Secret:         "secret",
+				Timeout:        time.Second,
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+			}, zap.NewNop())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go sender.Run(ctx)
+
+			sender.Notify(rec)
+
+			assert.Eventually(t, func() bool {
+				callbacks, err := storage.LoadCallbacks()
+				return err == nil && len(callbacks) == 0
+			}, time.Second, 10*time.Millisecond, "callback must be dropped in the end")
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+		})
+	}
+}